@@ -0,0 +1,291 @@
+// Package client looks up webfingers on any WebFinger server (RFC 7033).
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+const (
+	// DefaultMaxRedirects is the default number of redirects followed per request.
+	DefaultMaxRedirects = 3
+	// DefaultMaxResponseSize is the default limit on the size of response bodies.
+	DefaultMaxResponseSize = 1 << 20
+	// DefaultTimeout is the timeout of the default HTTP client.
+	DefaultTimeout = 10 * time.Second
+
+	// JRDContentType is the content type of webfinger responses (RFC 7033, section 10.2).
+	JRDContentType = "application/jrd+json"
+)
+
+var (
+	// ErrNotFound is returned when the server has no webfinger for the resource.
+	ErrNotFound = errors.New("resource not found")
+	// ErrUnexpectedStatus is returned when the server answers with an unexpected status code.
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// ErrInvalidJRD is returned when the response is not a valid JRD document.
+	ErrInvalidJRD = errors.New("invalid JRD")
+	// ErrResponseTooLarge is returned when the response is larger than the size limit.
+	ErrResponseTooLarge = errors.New("response too large")
+	// ErrTooManyRedirects is returned when a request is redirected more times than allowed.
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrInsecureRedirect is returned when an HTTPS request is redirected to plain HTTP.
+	ErrInsecureRedirect = errors.New("insecure redirect")
+	// ErrNoHost is returned when the resource has no host to query.
+	ErrNoHost = errors.New("resource has no host")
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. Its redirect policy
+// is replaced by the client's own.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithMaxRedirects sets how many redirects are followed per request.
+func WithMaxRedirects(n int) Option {
+	return func(c *Client) {
+		c.maxRedirects = n
+	}
+}
+
+// WithMaxResponseSize sets the largest response body the client reads, in bytes.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *Client) {
+		c.maxResponseSize = n
+	}
+}
+
+// WithPlainHTTP makes the client query servers over plain HTTP instead of
+// HTTPS, which is only meant for local testing.
+func WithPlainHTTP() Option {
+	return func(c *Client) {
+		c.scheme = "http"
+	}
+}
+
+// Client looks up webfingers.
+type Client struct {
+	httpClient      *http.Client
+	maxRedirects    int
+	maxResponseSize int64
+	scheme          string
+}
+
+// New creates a new client.
+func New(opts ...Option) *Client {
+	c := &Client{
+		httpClient:      &http.Client{Timeout: DefaultTimeout},
+		maxRedirects:    DefaultMaxRedirects,
+		maxResponseSize: DefaultMaxResponseSize,
+		scheme:          "https",
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Copy the HTTP client so the redirect policy doesn't leak into the caller's
+	client := *c.httpClient
+	client.CheckRedirect = c.checkRedirect
+	c.httpClient = &client
+
+	return c
+}
+
+// Lookup looks up the webfinger of a resource using a default client.
+func Lookup(ctx context.Context, resource string, rels ...string) (*webfingers.WebFinger, error) {
+	return New().Lookup(ctx, resource, rels...)
+}
+
+// Lookup looks up the webfinger of a resource, which can be a user@host
+// address or any URI with a host. If rels are given, the server is asked to
+// only return links with those relation types.
+//
+// The webfinger endpoint of the host is queried first. If it can't be reached
+// or doesn't answer with a JRD document, the LRDD template from the host's
+// host-meta document is tried instead. Not found responses are final.
+func (c *Client) Lookup(ctx context.Context, resource string, rels ...string) (*webfingers.WebFinger, error) {
+	resp, err := c.Fetch(ctx, resource, rels...)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.WebFinger, nil
+}
+
+// Response is a webfinger response.
+type Response struct {
+	// WebFinger is the decoded response.
+	WebFinger *webfingers.WebFinger
+	// Resource is the normalized resource that was looked up.
+	Resource string
+	// URL is the URL the response came from, after redirects.
+	URL *url.URL
+	// Header holds the response headers.
+	Header http.Header
+	// Body is the raw response body.
+	Body []byte
+}
+
+// Fetch is like Lookup, but returns the whole response.
+func (c *Client) Fetch(ctx context.Context, resource string, rels ...string) (*Response, error) {
+	resource, err := webfingers.Normalize(resource)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing resource: %w", err)
+	}
+
+	host, err := resourceHost(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"resource": {resource}, "rel": rels}
+	endpoint := &url.URL{
+		Scheme:   c.scheme,
+		Host:     host,
+		Path:     handler.WebfingerPath,
+		RawQuery: query.Encode(),
+	}
+
+	resp, err := c.fetch(ctx, endpoint, resource)
+	if err == nil {
+		return resp, nil
+	}
+
+	if !canFallBack(ctx, err) {
+		return nil, err
+	}
+
+	// Fall back to the endpoint advertised in host-meta, if it is another one
+	lrdd, lrddErr := c.lrddURL(ctx, host, resource, rels)
+	if lrddErr != nil || lrdd.String() == endpoint.String() {
+		return nil, err
+	}
+
+	return c.fetch(ctx, lrdd, resource)
+}
+
+// canFallBack reports whether a failed request to the webfinger endpoint can
+// be retried with the LRDD template from host-meta. That is only the case when
+// the endpoint is unreachable or isn't a webfinger endpoint, and not when it
+// answered that the resource doesn't exist or ctx is done.
+func canFallBack(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// Redirects refused by the client also fail the request
+	var urlErr *url.Error
+	unreachable := errors.As(err, &urlErr) && !errors.Is(err, ErrTooManyRedirects) && !errors.Is(err, ErrInsecureRedirect)
+	missing := errors.Is(err, ErrUnexpectedStatus) || errors.Is(err, ErrInvalidJRD)
+
+	return unreachable || missing
+}
+
+// fetch queries a webfinger URL and decodes the response.
+func (c *Client) fetch(ctx context.Context, endpoint *url.URL, resource string) (*Response, error) {
+	body, httpResp, err := c.get(ctx, endpoint, JRDContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	finger := &webfingers.WebFinger{}
+	if err := json.Unmarshal(body, finger); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJRD, err)
+	}
+
+	return &Response{
+		WebFinger: finger,
+		Resource:  resource,
+		URL:       httpResp.Request.URL,
+		Header:    httpResp.Header,
+		Body:      body,
+	}, nil
+}
+
+// get requests a URL and returns its body if the response is successful.
+func (c *Client) get(ctx context.Context, u *url.URL, accept string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, fmt.Errorf("%w %d from %s", ErrUnexpectedStatus, resp.StatusCode, u)
+	}
+
+	// Read one byte more than the limit to tell if the body is too large
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if int64(len(body)) > c.maxResponseSize {
+		return nil, nil, fmt.Errorf("%w: more than %d bytes from %s", ErrResponseTooLarge, c.maxResponseSize, u)
+	}
+
+	return body, resp, nil
+}
+
+// checkRedirect limits the number of redirects and forbids HTTPS downgrades.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > c.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, c.maxRedirects)
+	}
+
+	if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w to %s", ErrInsecureRedirect, req.URL)
+	}
+
+	return nil
+}
+
+// resourceHost returns the host to query for a normalized resource.
+func resourceHost(resource string) (string, error) {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", fmt.Errorf("error parsing resource: %w", err)
+	}
+
+	host := u.Host
+
+	// acct: and other opaque URIs like mailto: hold the host after the @
+	if u.Opaque != "" {
+		if i := strings.LastIndex(u.Opaque, "@"); i >= 0 {
+			host = u.Opaque[i+1:]
+		}
+	}
+
+	if host == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoHost, resource)
+	}
+
+	return host, nil
+}
@@ -0,0 +1,326 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/client"
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// newServer starts a TLS server with the given handlers and returns it with
+// a client that trusts it and the acct: resource of a user on its host.
+func newServer(t *testing.T, handlers map[string]http.Handler) (*httptest.Server, *client.Client, string) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.Handle(path, h)
+	}
+
+	ts := httptest.NewTLSServer(mux)
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	return ts, client.New(client.WithHTTPClient(ts.Client())), "acct:user@" + u.Host
+}
+
+// roundTripFunc is an http.RoundTripper from a function.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newFingers returns the webfingers of the user in resource.
+func newFingers(t *testing.T, resource string) webfingers.WebFingers {
+	t.Helper()
+
+	fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+		resource: {
+			"http://schema.org/name":                "John Doe",
+			"http://webfinger.net/rel/avatar":       "https://example.com/avatar.png",
+			"http://webfinger.net/rel/profile-page": "https://example.com/user",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	return fingers
+}
+
+func TestClient_Lookup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("looks up resources", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		// Resources without a scheme are acct: URIs
+		finger, err := c.Lookup(t.Context(), resource[len("acct:"):])
+		require.NoError(t, err)
+		require.Equal(t, resource, finger.Subject)
+		require.Equal(t, "John Doe", finger.Properties["http://schema.org/name"])
+		require.Len(t, finger.Links, 2)
+	})
+
+	t.Run("filters links by rel", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		finger, err := c.Lookup(t.Context(), resource, "http://webfinger.net/rel/avatar")
+		require.NoError(t, err)
+		require.Len(t, finger.Links, 1)
+		require.Equal(t, "http://webfinger.net/rel/avatar", finger.Links[0].Rel)
+	})
+
+	t.Run("returns the whole response", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		ts, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		resp, err := c.Fetch(t.Context(), resource)
+		require.NoError(t, err)
+		require.Equal(t, resource, resp.Resource)
+		require.Equal(t, client.JRDContentType, resp.Header.Get("Content-Type"))
+		require.Equal(t, ts.URL+handler.WebfingerPath, resp.URL.Scheme+"://"+resp.URL.Host+resp.URL.Path)
+		require.NotEmpty(t, resp.Body)
+	})
+
+	t.Run("falls back to host-meta", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}),
+			"/.well-known/host-meta": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/xrd+xml")
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+  <Link rel="lrdd" template="https://` + r.Host + `/custom?resource={uri}"></Link>
+</XRD>`))
+			}),
+			"/custom": handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		finger, err := c.Lookup(t.Context(), resource, "http://webfinger.net/rel/avatar")
+		require.NoError(t, err)
+		require.Equal(t, resource, finger.Subject)
+		require.Len(t, finger.Links, 1)
+	})
+
+	t.Run("falls back to JSON host-meta", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		_, c, resource := newServer(t, map[string]http.Handler{
+			// Sites without a webfinger endpoint often serve a page instead
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte("<html></html>"))
+			}),
+			"/.well-known/host-meta": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"links":[{"rel":"lrdd","template":"https://` + r.Host + `/custom?resource={uri}"}]}`))
+			}),
+			"/custom": handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		finger, err := c.Lookup(t.Context(), resource)
+		require.NoError(t, err)
+		require.Equal(t, resource, finger.Subject)
+	})
+
+	t.Run("doesn't fall back when the resource is not found", func(t *testing.T) {
+		t.Parallel()
+
+		var hostMetaQueried atomic.Bool
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: handler.StoreHandler(webfingers.NewStore(nil)),
+			"/.well-known/host-meta": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				hostMetaQueried.Store(true)
+				w.WriteHeader(http.StatusInternalServerError)
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrNotFound)
+		require.False(t, hostMetaQueried.Load())
+	})
+
+	t.Run("doesn't fall back when the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		ts, _, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				cancel()
+				w.WriteHeader(http.StatusInternalServerError)
+			}),
+		})
+
+		// Requests made with a done context never reach the server, so record
+		// them in the client
+		var hostMetaQueried atomic.Bool
+
+		httpClient := ts.Client()
+		transport := httpClient.Transport
+		httpClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasPrefix(r.URL.Path, "/.well-known/host-meta") {
+				hostMetaQueried.Store(true)
+			}
+
+			return transport.RoundTrip(r)
+		})
+
+		_, err := client.New(client.WithHTTPClient(httpClient)).Lookup(ctx, resource)
+		require.Error(t, err)
+		require.False(t, hostMetaQueried.Load())
+	})
+
+	t.Run("keeps the error when host-meta fails too", func(t *testing.T) {
+		t.Parallel()
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrUnexpectedStatus)
+	})
+
+	t.Run("errors on invalid JRDs", func(t *testing.T) {
+		t.Parallel()
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("<html></html>"))
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrInvalidJRD)
+	})
+
+	t.Run("errors on unexpected statuses", func(t *testing.T) {
+		t.Parallel()
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrUnexpectedStatus)
+	})
+
+	t.Run("limits the response size", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		ts, _, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: handler.StoreHandler(store),
+		})
+		store.Store(newFingers(t, resource))
+
+		c := client.New(client.WithHTTPClient(ts.Client()), client.WithMaxResponseSize(10))
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrResponseTooLarge)
+	})
+
+	t.Run("limits redirects", func(t *testing.T) {
+		t.Parallel()
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, r.URL.String(), http.StatusFound)
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrTooManyRedirects)
+	})
+
+	t.Run("refuses redirects to plain HTTP", func(t *testing.T) {
+		t.Parallel()
+
+		_, c, resource := newServer(t, map[string]http.Handler{
+			handler.WebfingerPath: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://"+r.Host+r.URL.String(), http.StatusFound)
+			}),
+		})
+
+		_, err := c.Lookup(t.Context(), resource)
+		require.ErrorIs(t, err, client.ErrInsecureRedirect)
+	})
+
+	t.Run("queries plain HTTP servers if asked", func(t *testing.T) {
+		t.Parallel()
+
+		store := webfingers.NewStore(nil)
+		ts := httptest.NewServer(handler.StoreHandler(store))
+		t.Cleanup(ts.Close)
+
+		resource := "acct:user@" + ts.Listener.Addr().String()
+		store.Store(newFingers(t, resource))
+
+		finger, err := client.New(client.WithPlainHTTP()).Lookup(t.Context(), resource)
+		require.NoError(t, err)
+		require.Equal(t, resource, finger.Subject)
+	})
+
+	t.Run("errors on invalid resources", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := client.New().Lookup(t.Context(), "invalid")
+		require.ErrorIs(t, err, webfingers.ErrInvalidResource)
+
+		_, err = client.New().Lookup(t.Context(), "urn:isbn:0451450523")
+		require.ErrorIs(t, err, client.ErrNoHost)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	httpClient := &http.Client{}
+
+	require.NotNil(t, client.New(client.WithHTTPClient(httpClient), client.WithMaxRedirects(1)))
+
+	// The redirect policy is not set on the given client
+	require.Nil(t, httpClient.CheckRedirect)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// xrdContentType is the content type of XRD documents.
+const xrdContentType = "application/xrd+xml"
+
+// ErrNoLRDD is returned when host-meta has no LRDD template.
+var ErrNoLRDD = errors.New("host-meta has no LRDD template")
+
+// xrdLinks holds the links of an XRD document.
+type xrdLinks struct {
+	Links []struct {
+		Rel      string `xml:"rel,attr"`
+		Template string `xml:"template,attr"`
+	} `xml:"Link"`
+}
+
+// lrddURL returns the webfinger URL of a resource built from the LRDD
+// template in the host-meta document of host.
+func (c *Client) lrddURL(ctx context.Context, host, resource string, rels []string) (*url.URL, error) {
+	hostMeta := &url.URL{Scheme: c.scheme, Host: host, Path: handler.HostMetaPath}
+
+	body, resp, err := c.get(ctx, hostMeta, xrdContentType+", "+JRDContentType+";q=0.9")
+	if err != nil {
+		return nil, err
+	}
+
+	var links []webfingers.Link
+
+	// Servers may answer in JSON, even though host-meta is XRD by default
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); strings.HasSuffix(mediaType, "json") {
+		doc := &webfingers.WebFinger{}
+		if err := json.Unmarshal(body, doc); err != nil {
+			return nil, fmt.Errorf("error decoding host-meta: %w", err)
+		}
+
+		links = doc.Links
+	} else {
+		doc := &xrdLinks{}
+		if err := xml.Unmarshal(body, doc); err != nil {
+			return nil, fmt.Errorf("error decoding host-meta: %w", err)
+		}
+
+		for _, link := range doc.Links {
+			links = append(links, webfingers.Link{Rel: link.Rel, Template: link.Template})
+		}
+	}
+
+	var template string
+
+	for _, link := range links {
+		if link.Rel == handler.LRDDRel && link.Template != "" {
+			template = link.Template
+
+			break
+		}
+	}
+
+	if template == "" {
+		return nil, ErrNoLRDD
+	}
+
+	u, err := url.Parse(strings.ReplaceAll(template, "{uri}", url.QueryEscape(resource)))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing LRDD template: %w", err)
+	}
+
+	// Add the rels to whatever the template has
+	if len(rels) > 0 {
+		query := u.Query()
+		query["rel"] = rels
+		u.RawQuery = query.Encode()
+	}
+
+	return u, nil
+}
@@ -27,6 +27,10 @@ func Run(version string) error {
 	subcommands := []*ff.Command{
 		newServerCmd(cfg),
 		newHealthcheckCmd(cfg),
+		newValidateCmd(cfg),
+		newMigrateCmd(cfg),
+		newLookupCmd(),
+		newVerifyCmd(),
 	}
 	cmd := newRootCmd(version, cfg, subcommands)
 
@@ -74,7 +78,13 @@ func newRootCmd(version string, cfg *config.Config, subcommands []*ff.Command) *
 	fs := ff.NewFlagSet(appName)
 
 	for _, cmd := range subcommands {
-		cmd.Flags = ff.NewFlagSet(cmd.Name).SetParent(fs)
+		// Subcommands may have flags of their own on top of the root ones
+		cmdFlags, ok := cmd.Flags.(*ff.FlagSet)
+		if !ok {
+			cmdFlags = ff.NewFlagSet(cmd.Name)
+		}
+
+		cmd.Flags = cmdFlags.SetParent(fs)
 	}
 
 	cmd := &ff.Command{
@@ -95,7 +105,101 @@ func newRootCmd(version string, cfg *config.Config, subcommands []*ff.Command) *
 	fs.StringVar(&cfg.Host, 'h', "host", defaultHost, "Host to listen on")
 	fs.StringVar(&cfg.Port, 'p', "port", "8080", "Port to listen on")
 	fs.StringVar(&cfg.URNPath, 'u', "urn-file", "urns.yml", "Path to the URNs file")
-	fs.StringVar(&cfg.FingerPath, 'f', "finger-file", "fingers.yml", "Path to the fingers file")
+	fs.StringVar(
+		&cfg.FingerPath, 'f', "finger-file", "fingers.yml",
+		"Comma-separated paths to fingers files, directories of them or globs, whose resources are merged",
+	)
+	fs.BoolVar(&cfg.IgnoreCase, 0, "ignore-case", "Ignore the case of the user part of acct: resources")
+	fs.StringVar(
+		&cfg.PublicURL, 0, "public-url", "",
+		"Public URL of the server, used in host-meta (defaults to the scheme and host of each request)",
+	)
+	fs.DurationVar(
+		&cfg.ReloadInterval, 0, "reload-interval", config.DefaultReloadInterval,
+		"How often to check the files for changes (0 to disable)",
+	)
+	fs.DurationVar(
+		&cfg.CacheMaxAge, 0, "cache-max-age", 0,
+		"How long clients may cache webfingers, unless they set their own max_age (0 to send no Cache-Control)",
+	)
+	fs.StringVar(
+		&cfg.CORSOrigins, 0, "cors-origins", config.DefaultCORSOrigins,
+		"Comma-separated list of origins allowed to make cross-origin requests (empty to disable CORS)",
+	)
+	fs.StringVar(
+		&cfg.CORSExposeHeaders, 0, "cors-expose-headers", "",
+		"Comma-separated list of response headers exposed to cross-origin clients",
+	)
+
+	fs.StringVar(
+		&cfg.VHostsPath, 0, "vhosts-file", "",
+		"Path to the virtual hosts file, to serve different webfingers per host (disabled if empty)",
+	)
+	fs.StringVar(
+		&cfg.VHostsDefault, 0, "vhosts-default", "",
+		"Virtual host that serves requests to unknown hosts (unknown hosts get 404 if empty)",
+	)
+	fs.StringVar(&cfg.TLSCert, 0, "tls-cert", "", "Path to the TLS certificate file, to serve HTTPS")
+	fs.StringVar(&cfg.TLSKey, 0, "tls-key", "", "Path to the TLS key file, to serve HTTPS")
+	fs.StringVar(
+		&cfg.TLSMinVersion, 0, "tls-min-version", config.DefaultTLSMinVersion,
+		"Minimum TLS version: 1.2 or 1.3",
+	)
+	fs.StringVar(
+		&cfg.RedirectAddr, 0, "redirect-addr", "",
+		"Address of a plain HTTP listener that redirects to HTTPS, like :80 (disabled if empty)",
+	)
+
+	fs.StringVar(
+		&cfg.Database, 0, "database", "",
+		"Path to a SQLite database to resolve resources from (disabled if empty)",
+	)
+
+	fs.IntVar(
+		&cfg.RateLimitHits, 0, "rate-limit-hits", 0,
+		"Number of webfinger requests that find a resource each client can make per minute (0 to disable)",
+	)
+	fs.IntVar(
+		&cfg.RateLimitMisses, 0, "rate-limit-misses", 0,
+		"Number of webfinger requests that find nothing each client can make per minute (0 to disable)",
+	)
+	fs.StringVar(
+		&cfg.RateLimitAllow, 0, "rate-limit-allow", "",
+		"Comma-separated list of IPs and CIDRs that are not rate limited",
+	)
+	fs.StringVar(
+		&cfg.RateLimitProxies, 0, "rate-limit-proxies", "",
+		"Comma-separated list of IPs and CIDRs of reverse proxies whose X-Forwarded-For header is trusted",
+	)
+	fs.IntVar(
+		&cfg.RateLimitClients, 0, "rate-limit-clients", config.DefaultRateLimitClients,
+		"Number of clients tracked by the rate limiter, forgetting the least recently seen ones",
+	)
+
+	fs.StringVar(
+		&cfg.SigningKeys, 0, "signing-keys", "",
+		"Comma-separated list of PEM or JWK key files. Webfingers are signed with the first one and every one is published (disabled if empty)",
+	)
+	fs.StringVar(
+		&cfg.JWKSPath, 0, "jwks-path", config.DefaultJWKSPath,
+		"Path where the public signing keys are served",
+	)
+
+	fs.StringVar(
+		&cfg.AdminAddr, 0, "admin-addr", "",
+		"Address of the admin API listener, like localhost:9091 (disabled if empty)",
+	)
+	fs.StringVar(&cfg.AdminToken, 0, "admin-token", "", "Bearer token required by the admin API")
+	fs.StringVar(
+		&cfg.AdminAuditFile, 0, "admin-audit-file", "",
+		"File where changes made through the admin API are recorded (defaults to the server logs)",
+	)
+
+	fs.BoolVar(&cfg.Metrics, 0, "metrics", "Serve Prometheus metrics at /metrics")
+	fs.StringVar(
+		&cfg.MetricsAddr, 0, "metrics-addr", "",
+		"Address of a separate listener for metrics, like :9090 (defaults to the main listener)",
+	)
 
 	return cmd
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -23,9 +24,19 @@ func newHealthcheckCmd(cfg *config.Config) *ff.Command {
 				Timeout: 5 * time.Second, //nolint:mnd // We want to use a constant
 			}
 
+			scheme := "http"
+			if cfg.TLSEnabled() {
+				scheme = "https"
+
+				// The certificate is for the public name, not the address we check
+				client.Transport = &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // Only checks liveness
+				}
+			}
+
 			// Create a new request
 			reqURL := url.URL{
-				Scheme: "http",
+				Scheme: scheme,
 				Host:   cfg.GetAddr(),
 				Path:   "/healthz",
 			}
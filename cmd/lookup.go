@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/peterbourgon/ff/v4"
+
+	"git.maronato.dev/maronato/finger/client"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// Output formats of the lookup command.
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+	outputRaw   = "raw"
+)
+
+func newLookupCmd() *ff.Command {
+	fs := ff.NewFlagSet("lookup")
+	rels := fs.StringListLong("rel", "Only return links with this relation type (repeatable)")
+	output := fs.StringLong("output", outputJSON, "Output format: json, table or raw")
+	plainHTTP := fs.BoolLong("http", "Query the server over plain HTTP instead of HTTPS")
+
+	return &ff.Command{
+		Name:      "lookup",
+		Usage:     "lookup [flags] <user@host | URI>",
+		ShortHelp: "Query a webfinger server for a resource",
+		Flags:     fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected a single resource, got %d", len(args)) //nolint:err113 // We want to return an error
+			}
+
+			if !slices.Contains([]string{outputJSON, outputTable, outputRaw}, *output) {
+				return fmt.Errorf("unknown output format %q", *output) //nolint:err113 // We want to return an error
+			}
+
+			opts := []client.Option{}
+			if *plainHTTP {
+				opts = append(opts, client.WithPlainHTTP())
+			}
+
+			resp, err := client.New(opts...).Fetch(ctx, args[0], *rels...)
+			if err != nil {
+				return fmt.Errorf("error looking up %s: %w", args[0], err)
+			}
+
+			for _, violation := range violations(resp, *rels) {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", violation)
+			}
+
+			return printFinger(resp.Body, resp.WebFinger, *output)
+		},
+	}
+}
+
+// violations returns the ways a response breaks RFC 7033.
+func violations(resp *client.Response, rels []string) []string {
+	var found []string
+
+	resource, finger := resp.Resource, resp.WebFinger
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != client.JRDContentType {
+		found = append(found, fmt.Sprintf("content type is %q instead of %q", mediaType, client.JRDContentType))
+	}
+
+	if resp.Header.Get("Access-Control-Allow-Origin") == "" {
+		found = append(found, "response has no Access-Control-Allow-Origin header")
+	}
+
+	// The subject may be another name of the resource, as long as it lists the resource as an alias
+	if subject, err := webfingers.Normalize(finger.Subject); finger.Subject != "" &&
+		(err != nil || subject != resource) && !slices.Contains(finger.Aliases, resource) {
+		found = append(found, fmt.Sprintf("subject %s does not match the requested resource %s", finger.Subject, resource))
+	}
+
+	for i, link := range finger.Links {
+		if link.Rel == "" {
+			found = append(found, fmt.Sprintf("link %d has no rel", i+1))
+		} else if len(rels) > 0 && !slices.Contains(rels, link.Rel) {
+			found = append(found, fmt.Sprintf("link %s was not requested with rel", link.Rel))
+		}
+	}
+
+	return found
+}
+
+// printFinger prints a webfinger in the given output format.
+func printFinger(body []byte, finger *webfingers.WebFinger, output string) error {
+	switch output {
+	case outputRaw:
+		if _, err := os.Stdout.Write(body); err != nil {
+			return fmt.Errorf("error writing response: %w", err)
+		}
+	case outputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd // Column padding
+
+		fmt.Fprintf(w, "subject\t%s\n", finger.Subject)
+
+		for _, alias := range finger.Aliases {
+			fmt.Fprintf(w, "alias\t%s\n", alias)
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(finger.Properties)) {
+			fmt.Fprintf(w, "property\t%s\t%s\n", name, finger.Properties[name])
+		}
+
+		for _, link := range finger.Links {
+			fmt.Fprintf(w, "link\t%s\t%s\n", link.Rel, cmp.Or(link.Href, link.Template))
+		}
+
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("error writing table: %w", err)
+		}
+	default:
+		var buf bytes.Buffer
+
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(finger); err != nil {
+			return fmt.Errorf("error encoding webfinger: %w", err)
+		}
+
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			return fmt.Errorf("error writing webfinger: %w", err)
+		}
+	}
+
+	return nil
+}
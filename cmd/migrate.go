@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/peterbourgon/ff/v4"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/sqlstore"
+)
+
+func newMigrateCmd(cfg *config.Config) *ff.Command {
+	fs := ff.NewFlagSet("migrate")
+	prune := fs.BoolLong("prune", "Delete the resources in the database that are not in the finger file")
+
+	return &ff.Command{
+		Name:      "migrate",
+		Usage:     "migrate --database <path> [flags]",
+		ShortHelp: "Import the URN and finger files into the database",
+		Flags:     fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			if cfg.Database == "" {
+				return fmt.Errorf("the --database flag is required") //nolint:err113 // We want to return an error
+			}
+
+			ctx = log.WithLogger(ctx, log.NewLogger(os.Stderr, cfg))
+
+			f := fingerreader.NewFingerReader()
+
+			if err := f.ReadFiles(cfg); err != nil {
+				return fmt.Errorf("error reading finger files: %w", err)
+			}
+
+			fingers, err := f.ReadFingerFile(ctx)
+			if err != nil {
+				return fmt.Errorf("error parsing finger files: %w", err)
+			}
+
+			db, err := sqlstore.Open(ctx, cfg.Database, cfg.Normalizer())
+			if err != nil {
+				return fmt.Errorf("error opening database: %w", err)
+			}
+			defer db.Close()
+
+			count, err := db.Import(ctx, fingers, *prune)
+			if err != nil {
+				return fmt.Errorf("error importing webfingers: %w", err)
+			}
+
+			fmt.Printf("Imported %d webfingers into %s\n", count, cfg.Database) //nolint:forbidigo // We want to print to stdout
+
+			return nil
+		},
+	}
+}
@@ -3,14 +3,23 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 
 	"github.com/peterbourgon/ff/v4"
+	"golang.org/x/sync/errgroup"
 
+	"git.maronato.dev/maronato/finger/internal/admin"
 	"git.maronato.dev/maronato/finger/internal/config"
-	"git.maronato.dev/maronato/finger/internal/fingerreader"
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+	"git.maronato.dev/maronato/finger/internal/reloader"
 	"git.maronato.dev/maronato/finger/internal/server"
+	"git.maronato.dev/maronato/finger/internal/sqlstore"
+	"git.maronato.dev/maronato/finger/webfingers"
 )
 
 const appName = "finger"
@@ -21,31 +30,102 @@ func newServerCmd(cfg *config.Config) *ff.Command {
 		Usage:     "serve [flags]",
 		ShortHelp: "Start the webfinger server",
 		Exec: func(ctx context.Context, _ []string) error {
+			if err := cfg.Validate(); err != nil {
+				return err //nolint:wrapcheck // The error is already descriptive
+			}
+
 			// Create a logger and add it to the context
 			l := log.NewLogger(os.Stderr, cfg)
 			ctx = log.WithLogger(ctx, l)
 
+			// Collect metrics if enabled
+			if cfg.Metrics {
+				routes := server.Routes
+				if cfg.Signed() {
+					routes = append(slices.Clone(routes), cfg.JWKSPath)
+				}
+
+				ctx = metrics.WithMetrics(ctx, metrics.New(routes...))
+			}
+
 			// Read the webfinger files
-			r := fingerreader.NewFingerReader()
+			var (
+				resolver webfingers.Resolver
+				r        *reloader.Reloader
+			)
 
-			err := r.ReadFiles(cfg)
-			if err != nil {
-				return fmt.Errorf("error reading finger files: %w", err)
+			if cfg.VHostsPath != "" {
+				hosts := webfingers.NewHostStore(nil, cfg.VHostsDefault)
+				resolver, r = hosts, reloader.NewVHosts(cfg, hosts)
+			} else {
+				store := webfingers.NewStore(nil)
+				resolver, r = store, reloader.New(cfg, store)
 			}
 
-			fingers, err := r.ReadFingerFile(ctx)
-			if err != nil {
-				return fmt.Errorf("error parsing finger files: %w", err)
+			if err := r.Load(ctx); err != nil {
+				return fmt.Errorf("error loading webfingers: %w", err)
 			}
 
-			l.Info(fmt.Sprintf("Loaded %d webfingers", len(fingers)))
+			// Resolve the resources missing from the files from the database
+			if cfg.Database != "" {
+				db, err := sqlstore.Open(ctx, cfg.Database, cfg.Normalizer())
+				if err != nil {
+					return fmt.Errorf("error opening database: %w", err)
+				}
+				defer db.Close()
 
-			// Start the server
-			if err := server.StartServer(ctx, cfg, fingers); err != nil {
-				return fmt.Errorf("error running server: %w", err)
+				count, err := db.Count(ctx)
+				if err != nil {
+					return fmt.Errorf("error opening database: %w", err)
+				}
+
+				l.Info(fmt.Sprintf("Found %d webfingers in the database", count), slog.String("database", cfg.Database))
+
+				resolver = webfingers.Chain(resolver, db)
 			}
 
-			return nil
+			// Reload the files on SIGHUP
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+
+			defer signal.Stop(hup)
+
+			var opts []server.Option
+
+			// Serve the admin API, applying its changes by reloading the files
+			if cfg.AdminAddr != "" {
+				audit := l
+
+				if cfg.AdminAuditFile != "" {
+					file, err := os.OpenFile(cfg.AdminAuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+					if err != nil {
+						return fmt.Errorf("error opening audit file: %w", err)
+					}
+					defer file.Close()
+
+					audit = slog.New(slog.NewJSONHandler(file, nil))
+				}
+
+				opts = append(opts, server.WithAdmin(admin.New(cfg, r.Load, audit).Handler(cfg.AdminToken)))
+			}
+
+			eg, egCtx := errgroup.WithContext(ctx)
+
+			// Watch the files for changes
+			eg.Go(func() error {
+				return r.Watch(egCtx, hup)
+			})
+
+			// Start the server
+			eg.Go(func() error {
+				if err := server.StartServer(egCtx, cfg, resolver, opts...); err != nil {
+					return fmt.Errorf("error running server: %w", err)
+				}
+
+				return nil
+			})
+
+			return eg.Wait() //nolint:wrapcheck // Errors are already wrapped
 		},
 	}
 }
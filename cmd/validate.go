@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/peterbourgon/ff/v4"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+)
+
+func newValidateCmd(cfg *config.Config) *ff.Command {
+	fs := ff.NewFlagSet("validate")
+	strict := fs.BoolLong("strict", "Fail on warnings too")
+
+	return &ff.Command{
+		Name:      "validate",
+		Usage:     "validate [flags]",
+		ShortHelp: "Check the URN and finger files for errors and suspicious content",
+		Flags:     fs,
+		Exec: func(_ context.Context, _ []string) error {
+			problems := fingerreader.Validate(cfg)
+
+			for _, problem := range problems {
+				fmt.Println(problem) //nolint:forbidigo // We want to print to stdout
+			}
+
+			errs := problems.Count(fingerreader.SeverityError)
+			warnings := problems.Count(fingerreader.SeverityWarning)
+
+			fmt.Printf("%d errors, %d warnings\n", errs, warnings) //nolint:forbidigo // We want to print to stdout
+
+			if errs > 0 || (*strict && warnings > 0) {
+				return fmt.Errorf("validation failed with %d errors and %d warnings", errs, warnings) //nolint:err113 // We want to return an error
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peterbourgon/ff/v4"
+
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+func newVerifyCmd() *ff.Command {
+	fs := ff.NewFlagSet("verify")
+	keyFiles := fs.StringListLong("key", "PEM, JWK or JWK Set file with the keys to verify with (repeatable)")
+	signature := fs.StringLong(
+		"signature", "",
+		"Detached JWS of the document, from the X-JWS-Signature header (the document is a JWS if empty)",
+	)
+
+	return &ff.Command{
+		Name:      "verify",
+		Usage:     "verify [flags] --key <file> [<document>]",
+		ShortHelp: "Check the signature of a webfinger offline, reading the document from stdin if none is given",
+		Flags:     fs,
+		Exec: func(_ context.Context, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected a single document, got %d", len(args)) //nolint:err113 // We want to return an error
+			}
+
+			if len(*keyFiles) == 0 {
+				return fmt.Errorf("at least one --key is required") //nolint:err113 // We want to return an error
+			}
+
+			keys, err := jws.LoadKeys(*keyFiles...)
+			if err != nil {
+				return fmt.Errorf("error loading keys: %w", err)
+			}
+
+			data, err := readDocument(args)
+			if err != nil {
+				return err
+			}
+
+			// Documents with a detached signature are checked as they are, and
+			// JWS are unwrapped so their payload can be piped along
+			var (
+				key     *jws.Key
+				payload []byte
+			)
+
+			if *signature != "" {
+				key, err = jws.VerifyDetached(*signature, data, keys)
+			} else {
+				payload, key, err = jws.VerifyJSON(data, keys)
+			}
+
+			if err != nil {
+				return fmt.Errorf("error verifying signature: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Valid signature by key %s\n", key.ID)
+
+			if _, err := os.Stdout.Write(payload); err != nil {
+				return fmt.Errorf("error writing payload: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// readDocument reads the document in the file given in args, or stdin if
+// there is none or it is "-".
+func readDocument(args []string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if len(args) == 0 || args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading document: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnyOrigin allows requests from any origin.
+const AnyOrigin = "*"
+
+// CORSConfig configures how the handler responds to cross-origin requests.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make requests. If empty,
+	// every origin is allowed.
+	AllowedOrigins []string
+	// ExposedHeaders are the response headers clients are allowed to read.
+	ExposedHeaders []string
+	// MaxAge is how long the result of a preflight request can be cached.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the value for the Access-Control-Allow-Origin header, or
+// an empty string if the origin is not allowed.
+func (c *CORSConfig) allowOrigin(origin string) string {
+	if len(c.AllowedOrigins) == 0 || slices.Contains(c.AllowedOrigins, AnyOrigin) {
+		return AnyOrigin
+	}
+
+	if origin != "" && slices.Contains(c.AllowedOrigins, origin) {
+		return origin
+	}
+
+	return ""
+}
+
+// corsHandler adds the CORS headers to the responses of next and answers
+// preflight requests.
+func corsHandler(next http.Handler, cfg *CORSConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := cfg.allowOrigin(origin)
+
+		// The response changes with the origin unless every origin is allowed
+		if allowed != AnyOrigin {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+
+			if len(cfg.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+		}
+
+		// Answer preflight requests without calling the next handler
+		if r.Method == http.MethodOptions {
+			if allowed != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+				if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+					w.Header().Add("Vary", "Access-Control-Request-Headers")
+				}
+
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+			}
+
+			w.Header().Set("Allow", "GET, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,160 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestWithCORS(t *testing.T) {
+	t.Parallel()
+
+	fingers := webfingers.WebFingers{
+		"acct:user@example.com": {
+			Subject: "acct:user@example.com",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		cfg         *handler.CORSConfig
+		method      string
+		headers     map[string]string
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		{
+			name:     "no CORS by default",
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://app.example.com"},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name:     "no preflight by default",
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://app.example.com"},
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "allows any origin by default",
+			cfg:      &handler.CORSConfig{},
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+				"Vary":                        "Accept",
+			},
+		},
+		{
+			name: "allows configured origins",
+			cfg: &handler.CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+				ExposedHeaders: []string{"ETag", "Last-Modified"},
+			},
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://app.example.com"},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.example.com",
+				"Access-Control-Expose-Headers": "ETag, Last-Modified",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name: "rejects other origins",
+			cfg: &handler.CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+			},
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://evil.example.com"},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "Origin",
+			},
+		},
+		{
+			name: "answers preflight requests",
+			cfg: &handler.CORSConfig{
+				MaxAge: time.Hour,
+			},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Accept",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, OPTIONS",
+				"Access-Control-Allow-Headers": "Accept",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name: "skips preflight headers for other origins",
+			cfg: &handler.CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+			},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		{
+			name:     "adds headers to errors",
+			cfg:      &handler.CORSConfig{},
+			method:   http.MethodPost,
+			wantCode: http.StatusMethodNotAllowed,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := []handler.Option{}
+			if tc.cfg != nil {
+				opts = append(opts, handler.WithCORS(*tc.cfg))
+			}
+
+			h := handler.WebfingerHandler(fingers, opts...)
+
+			r := httptest.NewRequest(tc.method, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, tc.wantCode, w.Code)
+
+			for k, v := range tc.wantHeaders {
+				require.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+}
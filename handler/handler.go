@@ -1,14 +1,62 @@
 package handler
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/jws"
 	"git.maronato.dev/maronato/finger/webfingers"
 )
 
-func WebfingerHandler(fingers webfingers.WebFingers) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// SignatureHeader is the header that holds the detached JWS of signed
+// responses (see WithSigner).
+const SignatureHeader = "X-JWS-Signature"
+
+// WebfingerHandler serves the given webfingers.
+func WebfingerHandler(fingers webfingers.WebFingers, opts ...Option) http.Handler {
+	return StoreHandler(webfingers.NewStore(fingers), opts...)
+}
+
+// StoreHandler serves the webfingers held by the store. The webfingers can be
+// replaced at any time, and each request uses the ones current when it started.
+func StoreHandler(store *webfingers.Store, opts ...Option) http.Handler {
+	return ResolverHandler(store, opts...)
+}
+
+// HostStoreHandler serves the webfingers of the virtual host each request is
+// made to, using its Host header.
+func HostStoreHandler(hosts *webfingers.HostStore, opts ...Option) http.Handler {
+	return ResolverHandler(hosts, opts...)
+}
+
+// ResolverHandler serves the webfingers returned by the resolver. The host
+// each request is made to is added to the context given to the resolver (see
+// webfingers.HostFromContext).
+//
+// Resolver errors are answered with 404 for webfingers.ErrNotFound, 410 for
+// webfingers.ErrGone, 400 for webfingers.ErrInvalidResource, 503 for
+// webfingers.ErrUnavailable, 504 for timeouts and 500 for anything else.
+func ResolverHandler(resolver webfingers.Resolver, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	offers := webfingerOffers
+	if o.signer != nil {
+		offers = signedOffers
+	}
+
+	return o.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Only handle GET requests
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -16,6 +64,17 @@ func WebfingerHandler(fingers webfingers.WebFingers) http.Handler {
 			return
 		}
 
+		// Every response depends on the Accept header, including errors
+		w.Header().Add("Vary", "Accept")
+
+		// Pick the format of the response, JRD unless XRD is preferred
+		format, ok := negotiate(r.Header.Get("Accept"), offers)
+		if !ok {
+			http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+
+			return
+		}
+
 		// Get the query params
 		q := r.URL.Query()
 
@@ -27,22 +86,184 @@ func WebfingerHandler(fingers webfingers.WebFingers) http.Handler {
 			return
 		}
 
-		// Get and validate resource
-		finger, ok := fingers[resource]
-		if !ok {
-			http.Error(w, "Resource not found", http.StatusNotFound)
+		// Normalize the resource so that every way of writing it matches
+		resource, err := o.normalizer.Normalize(resource)
+		if err != nil {
+			http.Error(w, "Invalid resource", http.StatusBadRequest)
 
 			return
 		}
 
-		// Set the content type
-		w.Header().Set("Content-Type", "application/jrd+json")
+		// Resolve the resource
+		finger, err := resolver.Resolve(webfingers.WithHost(r.Context(), r.Host), resource)
+		if err != nil || finger == nil {
+			status := errorStatus(err)
+
+			// Missing resources are expected, but failing resolvers are not
+			if status != http.StatusNotFound && status != http.StatusGone {
+				log.FromContextOrDefault(r.Context()).Error("Failed to resolve webfinger",
+					slog.String("resource", resource), slog.Int("status", status), slog.Any("error", err))
+			}
+
+			http.Error(w, http.StatusText(status), status)
+
+			return
+		}
+
+		// Only include the links with the requested relation types, if any
+		finger = finger.FilterLinks(q["rel"]...)
+
+		// Encode the response first, since the ETag is its hash
+		body, err := encode(finger, format.contentType)
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+
+			return
+		}
+
+		// The ETag is of the unsigned document and the signing key, so it
+		// changes with the key but not with each signature
+		var keyID string
+		if o.signer != nil {
+			keyID = o.signer.KeyID()
+		}
+
+		tag := etag(body, []byte(format.contentType), []byte(keyID))
+
+		// Some algorithms, like ECDSA, make a new signature on every request,
+		// so JWS responses with the same ETag are not byte for byte the same
+		if format.contentType == JOSEContentType {
+			tag = "W/" + tag
+		}
+
+		// Set the caching headers
+		w.Header().Set("ETag", tag)
 
-		// Write the response
-		if err := json.NewEncoder(w).Encode(finger); err != nil {
-			http.Error(w, "Error encoding json", http.StatusInternalServerError)
+		if maxAge := cmp.Or(finger.MaxAge, o.cacheMaxAge); maxAge > 0 {
+			w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(maxAge.Seconds())))
+		}
+
+		if !finger.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", finger.ModTime.UTC().Format(http.TimeFormat))
+		}
+
+		// Answer with 304 if the client's copy is still fresh, before paying
+		// for a signature
+		if notModified(r, tag, finger.ModTime) {
+			w.WriteHeader(http.StatusNotModified)
 
 			return
 		}
-	})
+
+		if o.signer != nil {
+			if body, err = sign(w, o.signer, body, format.contentType); err != nil {
+				for _, header := range []string{"ETag", "Cache-Control", "Last-Modified"} {
+					w.Header().Del(header)
+				}
+
+				http.Error(w, "Error signing response", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", format.contentType)
+
+		// Write the response. Documents are small, so ranges are not supported.
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+	}))
+}
+
+// encode encodes the webfinger as a document of the content type. JWS are
+// encoded as the JRD they sign.
+func encode(finger *webfingers.WebFinger, contentType string) ([]byte, error) {
+	if contentType == XRDContentType {
+		return finger.MarshalXRD() //nolint:wrapcheck // Already wrapped
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(finger); err != nil {
+		return nil, fmt.Errorf("error encoding JRD: %w", err)
+	}
+
+	return body.Bytes(), nil
+}
+
+// sign signs the body of a response. JWS responses wrap the JRD in a JWS,
+// and the others get a detached JWS of their body in the SignatureHeader.
+func sign(w http.ResponseWriter, s *jws.Signer, body []byte, contentType string) ([]byte, error) {
+	if contentType == JOSEContentType {
+		return s.SignJSON(body, strings.TrimPrefix(JRDContentType, "application/")) //nolint:wrapcheck // Already wrapped
+	}
+
+	sig, err := s.SignDetached(body, strings.TrimPrefix(contentType, "application/"))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Already wrapped
+	}
+
+	w.Header().Set(SignatureHeader, sig)
+
+	return body, nil
+}
+
+// errorStatus returns the HTTP status for an error returned by a resolver.
+func errorStatus(err error) int {
+	switch {
+	case err == nil, errors.Is(err, webfingers.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, webfingers.ErrGone):
+		return http.StatusGone
+	case errors.Is(err, webfingers.ErrInvalidResource):
+		return http.StatusBadRequest
+	case errors.Is(err, webfingers.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// notModified reports whether the client's copy of a response is still
+// fresh, according to its If-None-Match header or, if it doesn't have one,
+// its If-Modified-Since header.
+func notModified(r *http.Request, tag string, modTime time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		// ETags are compared weakly (RFC 9110, section 13.1.2)
+		for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// Dates in headers have a precision of seconds
+	return !modTime.Truncate(time.Second).After(since)
+}
+
+// etag returns a strong ETag for the parts of a response, like its body and
+// the content type it is served as.
+func etag(parts ...[]byte) string {
+	h := sha256.New()
+
+	for _, part := range parts {
+		h.Write(part)
+		// Separate the parts, so they can't run into each other
+		h.Write([]byte{0})
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
 }
@@ -1,19 +1,27 @@
 package handler_test
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"git.maronato.dev/maronato/finger/handler"
 	"git.maronato.dev/maronato/finger/internal/config"
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/jws"
 	"git.maronato.dev/maronato/finger/webfingers"
 )
 
@@ -28,6 +36,14 @@ func TestWebfingerHandler(t *testing.T) {
 					Rel:  "http://webfinger.net/rel/profile-page",
 					Href: "https://example.com/user",
 				},
+				{
+					Rel:  "http://webfinger.net/rel/avatar",
+					Href: "https://example.com/user.png",
+				},
+				{
+					Rel:  "http://openid.net/specs/connect/1.0/issuer",
+					Href: "https://sso.example.com/",
+				},
 			},
 			Properties: map[string]string{
 				"http://webfinger.net/rel/name": "John Doe",
@@ -35,10 +51,14 @@ func TestWebfingerHandler(t *testing.T) {
 		},
 		"acct:other@example.com": {
 			Subject: "acct:other@example.com",
+			Aliases: []string{"https://example.com/@other"},
 			Properties: map[string]string{
 				"http://webfinger.net/rel/name": "Jane Doe",
 			},
 		},
+		"acct:user@xn--bcher-kva.example": {
+			Subject: "acct:user@xn--bcher-kva.example",
+		},
 		"https://example.com/user": {
 			Subject: "https://example.com/user",
 			Properties: map[string]string{
@@ -47,9 +67,15 @@ func TestWebfingerHandler(t *testing.T) {
 		},
 	}
 
+	// Aliases point to the same webfinger as their subject
+	fingers["https://example.com/@other"] = fingers["acct:other@example.com"]
+
 	tests := []struct {
 		name            string
 		resource        string
+		subject         string
+		rels            []string
+		wantRels        []string
 		wantCode        int
 		alternateMethod string
 	}{
@@ -68,11 +94,83 @@ func TestWebfingerHandler(t *testing.T) {
 			resource: "https://example.com/user",
 			wantCode: http.StatusOK,
 		},
+		{
+			name:     "alias resource",
+			resource: "https://example.com/@other",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "filters links by rel",
+			resource: "acct:user@example.com",
+			rels:     []string{"http://webfinger.net/rel/avatar"},
+			wantRels: []string{"http://webfinger.net/rel/avatar"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "filters links by multiple rels",
+			resource: "acct:user@example.com",
+			rels: []string{
+				"http://webfinger.net/rel/avatar",
+				"http://openid.net/specs/connect/1.0/issuer",
+			},
+			wantRels: []string{
+				"http://openid.net/specs/connect/1.0/issuer",
+				"http://webfinger.net/rel/avatar",
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "filters out every link on unknown rel",
+			resource: "acct:user@example.com",
+			rels:     []string{"http://example.com/rel/unknown"},
+			wantRels: []string{},
+			wantCode: http.StatusOK,
+		},
 		{
 			name:     "resource missing acct:",
 			resource: "user@example.com",
+			subject:  "acct:user@example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "resource with uppercase host",
+			resource: "acct:user@EXAMPLE.com",
+			subject:  "acct:user@example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "percent-encoded resource",
+			resource: "acct:user%40example.com",
+			subject:  "acct:user@example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unicode host resource",
+			resource: "acct:user@bücher.example",
+			subject:  "acct:user@xn--bcher-kva.example",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "url resource with uppercase host",
+			resource: "https://EXAMPLE.com/user",
+			subject:  "https://example.com/user",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "local part is case sensitive",
+			resource: "acct:User@example.com",
 			wantCode: http.StatusNotFound,
 		},
+		{
+			name:     "unknown resource",
+			resource: "acct:unknown@example.com",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "malformed resource",
+			resource: "invalid",
+			wantCode: http.StatusBadRequest,
+		},
 		{
 			name:     "resource missing",
 			resource: "",
@@ -98,11 +196,19 @@ func TestWebfingerHandler(t *testing.T) {
 
 			ctx = log.WithLogger(ctx, l)
 
+			// Build the query
+			query := url.Values{}
+			query.Set("resource", tc.resource)
+
+			for _, rel := range tc.rels {
+				query.Add("rel", rel)
+			}
+
 			// Create a new request
 			r, _ := http.NewRequestWithContext(
 				ctx,
 				tc.alternateMethod,
-				"/.well-known/webfinger?resource="+tc.resource,
+				"/.well-known/webfinger?"+query.Encode(),
 				http.NoBody,
 			)
 
@@ -122,7 +228,7 @@ func TestWebfingerHandler(t *testing.T) {
 				// Check the content type
 				require.Equal(t, "application/jrd+json", w.Header().Get("Content-Type"))
 
-				fingerWant := fingers[tc.resource]
+				fingerWant := fingers[cmp.Or(tc.subject, tc.resource)]
 				fingerGot := &webfingers.WebFinger{}
 
 				// Decode the response body
@@ -138,6 +244,20 @@ func TestWebfingerHandler(t *testing.T) {
 					return fingerWant.Links[i].Rel < fingerWant.Links[j].Rel
 				})
 
+				// If rels were requested, only the links should change
+				if tc.wantRels != nil {
+					gotRels := []string{}
+					for _, link := range fingerGot.Links {
+						gotRels = append(gotRels, link.Rel)
+					}
+
+					require.Equal(t, tc.wantRels, gotRels)
+					require.Equal(t, fingerWant.Subject, fingerGot.Subject)
+					require.Equal(t, fingerWant.Properties, fingerGot.Properties)
+
+					return
+				}
+
 				// Check the response body
 				require.Equal(t, fingerWant, fingerGot)
 			}
@@ -145,6 +265,528 @@ func TestWebfingerHandler(t *testing.T) {
 	}
 }
 
+func TestWithNormalizer(t *testing.T) {
+	t.Parallel()
+
+	fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+		"User@Example.com": {
+			"prop1": "value1",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	n := webfingers.Normalizer{IgnoreLocalCase: true}
+
+	fingers, err = fingers.Normalized(n)
+	require.NoError(t, err)
+
+	h := handler.WebfingerHandler(fingers, handler.WithNormalizer(n))
+
+	for _, resource := range []string{"acct:user@example.com", "USER@EXAMPLE.COM", "acct:User@example.com"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource="+url.QueryEscape(resource), http.NoBody)
+
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code, resource)
+	}
+}
+
+func TestResolverHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		err    error
+		want   int
+		logged bool
+	}{
+		{"not found", webfingers.ErrNotFound, http.StatusNotFound, false},
+		{"gone", fmt.Errorf("deleted: %w", webfingers.ErrGone), http.StatusGone, false},
+		{"invalid resource", webfingers.ErrInvalidResource, http.StatusBadRequest, true},
+		{"unavailable", webfingers.ErrUnavailable, http.StatusServiceUnavailable, true},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, true},
+		{"other errors", errors.New("database error"), http.StatusInternalServerError, true},
+		{"no webfinger", nil, http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := handler.ResolverHandler(webfingers.ResolverFunc(
+				func(_ context.Context, _ string) (*webfingers.WebFinger, error) {
+					return nil, tc.err
+				},
+			))
+
+			logs := &strings.Builder{}
+			ctx := log.WithLogger(context.Background(), log.NewLogger(logs, config.NewConfig()))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequestWithContext(ctx, http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, tc.want, w.Code)
+
+			// Only failures are logged
+			if tc.logged {
+				require.Contains(t, logs.String(), "Failed to resolve webfinger")
+			} else {
+				require.Empty(t, logs.String())
+			}
+		})
+	}
+
+	t.Run("resolves normalized resources", func(t *testing.T) {
+		t.Parallel()
+
+		var got string
+
+		h := handler.ResolverHandler(webfingers.ResolverFunc(
+			func(_ context.Context, resource string) (*webfingers.WebFinger, error) {
+				got = resource
+
+				return &webfingers.WebFinger{Subject: resource}, nil
+			},
+		))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=user@EXAMPLE.com", http.NoBody)
+
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "acct:user@example.com", got)
+	})
+}
+
+func TestHostStoreHandler(t *testing.T) {
+	t.Parallel()
+
+	example, err := webfingers.NewWebFingers(webfingers.Resources{
+		"alice@example.com": {"name": "Alice"},
+	}, nil)
+	require.NoError(t, err)
+
+	other, err := webfingers.NewWebFingers(webfingers.Resources{
+		"bob@other.com": {"name": "Bob"},
+	}, nil)
+	require.NoError(t, err)
+
+	h := handler.HostStoreHandler(webfingers.NewHostStore(webfingers.VirtualHosts{
+		"example.com": {Fingers: example},
+		"other.com":   {Fingers: other},
+	}, ""))
+
+	for _, tc := range []struct {
+		host     string
+		resource string
+		want     int
+	}{
+		{"example.com", "acct:alice@example.com", http.StatusOK},
+		{"other.com:8080", "acct:bob@other.com", http.StatusOK},
+		{"other.com", "acct:alice@example.com", http.StatusNotFound},
+		{"unknown.com", "acct:alice@example.com", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource="+url.QueryEscape(tc.resource), http.NoBody)
+		r.Host = tc.host
+
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, tc.want, w.Code, tc.host+" "+tc.resource)
+	}
+}
+
+func TestWebfingerHandler_ContentNegotiation(t *testing.T) {
+	t.Parallel()
+
+	fingers := webfingers.WebFingers{
+		"acct:user@example.com": {
+			Subject: "acct:user@example.com",
+			Aliases: []string{"https://example.com/@user"},
+			Links: []webfingers.Link{
+				{
+					Rel:    "http://webfinger.net/rel/profile-page",
+					Href:   "https://example.com/user",
+					Titles: map[string]string{"en": "Profile"},
+				},
+			},
+			Properties: map[string]string{
+				"http://webfinger.net/rel/name": "John Doe",
+			},
+		},
+	}
+
+	h := handler.WebfingerHandler(fingers)
+
+	tests := []struct {
+		name     string
+		accept   string
+		wantCode int
+		wantType string
+	}{
+		{name: "no accept header", accept: "", wantCode: http.StatusOK, wantType: "application/jrd+json"},
+		{name: "any type", accept: "*/*", wantCode: http.StatusOK, wantType: "application/jrd+json"},
+		{name: "jrd", accept: "application/jrd+json", wantCode: http.StatusOK, wantType: "application/jrd+json"},
+		{name: "json", accept: "application/json", wantCode: http.StatusOK, wantType: "application/jrd+json"},
+		{name: "xrd", accept: "application/xrd+xml", wantCode: http.StatusOK, wantType: "application/xrd+xml"},
+		{name: "xml", accept: "application/xml", wantCode: http.StatusOK, wantType: "application/xrd+xml"},
+		{
+			name:     "xrd preferred",
+			accept:   "application/jrd+json;q=0.5, application/xrd+xml",
+			wantCode: http.StatusOK,
+			wantType: "application/xrd+xml",
+		},
+		{
+			name:     "xrd preferred over wildcards",
+			accept:   "application/xrd+xml, */*;q=0.1",
+			wantCode: http.StatusOK,
+			wantType: "application/xrd+xml",
+		},
+		{
+			name:     "jrd excluded",
+			accept:   "application/*, application/jrd+json;q=0, application/json;q=0",
+			wantCode: http.StatusOK,
+			wantType: "application/xrd+xml",
+		},
+		{name: "invalid accept header", accept: "not a media type", wantCode: http.StatusOK, wantType: "application/jrd+json"},
+		{name: "unsupported type", accept: "text/html", wantCode: http.StatusNotAcceptable},
+		{name: "every type excluded", accept: "*/*;q=0", wantCode: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+			r.Header.Set("Accept", tc.accept)
+
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, tc.wantCode, w.Code)
+			require.Equal(t, "Accept", w.Header().Get("Vary"))
+
+			if tc.wantCode == http.StatusOK {
+				require.Equal(t, tc.wantType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	t.Run("varies errors by the accept header", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:missing@example.com", http.NoBody)
+
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "Accept", w.Header().Get("Vary"))
+	})
+
+	t.Run("renders XRD documents", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+		r.Header.Set("Accept", "application/xrd+xml")
+
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+  <Subject>acct:user@example.com</Subject>
+  <Alias>https://example.com/@user</Alias>
+  <Property type="http://webfinger.net/rel/name">John Doe</Property>
+  <Link rel="http://webfinger.net/rel/profile-page" href="https://example.com/user">
+    <Title xml:lang="en">Profile</Title>
+  </Link>
+</XRD>`, w.Body.String())
+	})
+
+	t.Run("uses different ETags for each format", func(t *testing.T) {
+		t.Parallel()
+
+		etags := make(map[string]bool)
+
+		for _, accept := range []string{"application/jrd+json", "application/xrd+xml"} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+			r.Header.Set("Accept", accept)
+
+			h.ServeHTTP(w, r)
+
+			etags[w.Header().Get("ETag")] = true
+		}
+
+		require.Len(t, etags, 2)
+	})
+}
+
+func TestWithSigner(t *testing.T) {
+	t.Parallel()
+
+	fingers := webfingers.WebFingers{
+		"acct:user@example.com": {
+			Subject: "acct:user@example.com",
+			Properties: map[string]string{
+				"http://webfinger.net/rel/name": "John Doe",
+			},
+		},
+	}
+
+	s := newSigner(t)
+	h := handler.WebfingerHandler(fingers, handler.WithSigner(s))
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+		r.Header.Set("Accept", accept)
+
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("attaches a detached signature", func(t *testing.T) {
+		t.Parallel()
+
+		for _, accept := range []string{"application/jrd+json", "application/xrd+xml"} {
+			w := get(accept)
+			require.Equal(t, http.StatusOK, w.Code, accept)
+
+			key, err := jws.VerifyDetached(w.Header().Get(handler.SignatureHeader), w.Body.Bytes(), s.Keys())
+			require.NoError(t, err, accept)
+			require.Equal(t, s.Keys()[0].ID, key.ID)
+		}
+	})
+
+	t.Run("serves JWS when asked", func(t *testing.T) {
+		t.Parallel()
+
+		w := get("application/jose+json")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, handler.JOSEContentType, w.Header().Get("Content-Type"))
+		require.Empty(t, w.Header().Get(handler.SignatureHeader))
+
+		payload, _, err := jws.VerifyJSON(w.Body.Bytes(), s.Keys())
+		require.NoError(t, err)
+		require.Equal(t, get("application/jrd+json").Body.String(), string(payload))
+
+		// Representations have their own ETags, which don't change with the signature
+		require.NotEqual(t, get("application/jrd+json").Header().Get("ETag"), w.Header().Get("ETag"))
+		require.Equal(t, get("application/jose+json").Header().Get("ETag"), w.Header().Get("ETag"))
+
+		// Signatures can differ on every request, so the ETag is weak
+		require.True(t, strings.HasPrefix(w.Header().Get("ETag"), "W/"))
+	})
+
+	t.Run("changes ETags with the signing key", func(t *testing.T) {
+		t.Parallel()
+
+		rotated := newSigner(t)
+		h := handler.WebfingerHandler(fingers, handler.WithSigner(rotated))
+
+		for _, accept := range []string{"application/jrd+json", "application/jose+json"} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+			r.Header.Set("Accept", accept)
+
+			h.ServeHTTP(w, r)
+			tag := w.Header().Get("ETag")
+
+			require.NoError(t, rotated.SetKeys(newKey(t)))
+
+			w = httptest.NewRecorder()
+			r.Header.Set("If-None-Match", tag)
+			h.ServeHTTP(w, r)
+
+			// Clients get the new signature instead of a 304
+			require.Equal(t, http.StatusOK, w.Code, accept)
+			require.NotEqual(t, tag, w.Header().Get("ETag"), accept)
+		}
+	})
+
+	t.Run("answers 304 without signing", func(t *testing.T) {
+		t.Parallel()
+
+		tag := get("application/jrd+json").Header().Get("ETag")
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+		r.Header.Set("Accept", "application/jrd+json")
+		r.Header.Set("If-None-Match", tag)
+
+		h.ServeHTTP(w, r)
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Equal(t, tag, w.Header().Get("ETag"))
+		require.Empty(t, w.Header().Get(handler.SignatureHeader))
+	})
+
+	t.Run("serves JRD by default", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, handler.JRDContentType, get("*/*").Header().Get("Content-Type"))
+		require.Equal(t, handler.JRDContentType, get("").Header().Get("Content-Type"))
+	})
+
+	t.Run("doesn't serve JWS without a signer", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:user@example.com", http.NoBody)
+		r.Header.Set("Accept", "application/jose+json")
+
+		handler.WebfingerHandler(fingers).ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+		require.Empty(t, w.Header().Get(handler.SignatureHeader))
+	})
+}
+
+func TestWebfingerHandler_Caching(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newStore := func(t *testing.T) *webfingers.Store {
+		t.Helper()
+
+		fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+			"user@example.com": {
+				"avatar":  "https://example.com/avatar.png",
+				"profile": "https://example.com/user",
+			},
+			"cached@example.com": {
+				"max_age": "1h",
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		fingers.SetModTimes(nil, modTime)
+
+		return webfingers.NewStore(fingers)
+	}
+
+	get := func(h http.Handler, query string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?"+query, http.NoBody)
+
+		maps.Copy(r.Header, header)
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("sets validators", func(t *testing.T) {
+		t.Parallel()
+
+		h := handler.StoreHandler(newStore(t))
+
+		w := get(h, "resource=acct:user@example.com", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NotEmpty(t, w.Header().Get("ETag"))
+		require.Equal(t, modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+		require.Empty(t, w.Header().Get("Cache-Control"))
+
+		// ETags are stable
+		require.Equal(t, w.Header().Get("ETag"), get(h, "resource=acct:user@example.com", nil).Header().Get("ETag"))
+
+		// Filtered responses are different representations
+		filtered := get(h, "resource=acct:user@example.com&rel=avatar", nil)
+		require.NotEqual(t, w.Header().Get("ETag"), filtered.Header().Get("ETag"))
+	})
+
+	t.Run("answers conditional requests", func(t *testing.T) {
+		t.Parallel()
+
+		h := handler.StoreHandler(newStore(t))
+		etag := get(h, "resource=acct:user@example.com", nil).Header().Get("ETag")
+
+		w := get(h, "resource=acct:user@example.com", http.Header{"If-None-Match": {etag}})
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Empty(t, w.Body.String())
+
+		w = get(h, "resource=acct:user@example.com", http.Header{"If-None-Match": {`"other"`}})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// Lists and weak ETags match too
+		w = get(h, "resource=acct:user@example.com", http.Header{"If-None-Match": {`"other", W/` + etag}})
+		require.Equal(t, http.StatusNotModified, w.Code)
+
+		// If-None-Match takes precedence
+		w = get(h, "resource=acct:user@example.com", http.Header{
+			"If-None-Match":     {`"other"`},
+			"If-Modified-Since": {modTime.Format(http.TimeFormat)},
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = get(h, "resource=acct:user@example.com", http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}})
+		require.Equal(t, http.StatusNotModified, w.Code)
+
+		w = get(h, "resource=acct:user@example.com", http.Header{
+			"If-Modified-Since": {modTime.Add(-time.Hour).Format(http.TimeFormat)},
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("ignores ranges", func(t *testing.T) {
+		t.Parallel()
+
+		h := handler.StoreHandler(newStore(t))
+		full := get(h, "resource=acct:user@example.com", nil)
+
+		w := get(h, "resource=acct:user@example.com", http.Header{"Range": {"bytes=0-9"}})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("Accept-Ranges"))
+		require.Equal(t, full.Body.String(), w.Body.String())
+		require.Equal(t, strconv.Itoa(full.Body.Len()), w.Header().Get("Content-Length"))
+	})
+
+	t.Run("changes validators when the resource changes", func(t *testing.T) {
+		t.Parallel()
+
+		store := newStore(t)
+		h := handler.StoreHandler(store)
+		etag := get(h, "resource=acct:user@example.com", nil).Header().Get("ETag")
+
+		fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+			"user@example.com": {"avatar": "https://example.com/new-avatar.png"},
+		}, nil)
+		require.NoError(t, err)
+
+		fingers.SetModTimes(store.Load(), modTime.Add(time.Hour))
+		store.Store(fingers)
+
+		w := get(h, "resource=acct:user@example.com", http.Header{"If-None-Match": {etag}})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NotEqual(t, etag, w.Header().Get("ETag"))
+		require.Equal(t, modTime.Add(time.Hour).Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	})
+
+	t.Run("sets the max age", func(t *testing.T) {
+		t.Parallel()
+
+		h := handler.StoreHandler(newStore(t), handler.WithCacheMaxAge(5*time.Minute))
+
+		w := get(h, "resource=acct:user@example.com", nil)
+		require.Equal(t, "max-age=300", w.Header().Get("Cache-Control"))
+
+		// Resources can set their own
+		w = get(h, "resource=acct:cached@example.com", nil)
+		require.Equal(t, "max-age=3600", w.Header().Get("Cache-Control"))
+	})
+}
+
 func BenchmarkWebfingerHandler(b *testing.B) {
 	fingers, err := webfingers.NewWebFingers(
 		webfingers.Resources{
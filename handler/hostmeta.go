@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// WebfingerPath is the path where webfingers are served.
+const WebfingerPath = "/.well-known/webfinger"
+
+// HostMetaPath is the path where the host-meta XRD document is served.
+const HostMetaPath = "/.well-known/host-meta"
+
+// HostMetaJSONPath is the path where the host-meta JRD document is served.
+const HostMetaJSONPath = "/.well-known/host-meta.json"
+
+// LRDDRel is the relation type of the host-meta link that points to the webfinger endpoint.
+const LRDDRel = "lrdd"
+
+// hostMetaDocument is a host-meta document. Unlike a webfinger, it has no subject.
+type hostMetaDocument struct {
+	Links []webfingers.Link `json:"links"`
+}
+
+// hostMeta returns the host-meta document pointing to the webfinger endpoint at baseURL.
+// If baseURL is empty, it is built from the request.
+func hostMeta(baseURL string, r *http.Request) *hostMetaDocument {
+	if baseURL == "" {
+		// Only use what the cache key of the response is made of. Headers set
+		// by proxies, like X-Forwarded-Proto, can be sent by anyone and would
+		// let clients poison cached responses.
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		baseURL = scheme + "://" + r.Host
+	}
+
+	return &hostMetaDocument{
+		Links: []webfingers.Link{
+			{
+				Rel:      LRDDRel,
+				Template: strings.TrimSuffix(baseURL, "/") + WebfingerPath + "?resource={uri}",
+			},
+		},
+	}
+}
+
+// HostMetaHandler serves the host-meta XRD document (RFC 6415), with an LRDD
+// template that points to the webfinger endpoint at baseURL. If baseURL is
+// empty, it is built from the scheme and host of each request, ignoring
+// forwarded headers, so servers behind a proxy should set it.
+func HostMetaHandler(baseURL string, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	return o.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		doc := &webfingers.WebFinger{Links: hostMeta(baseURL, r).Links}
+
+		data, err := doc.MarshalXRD()
+		if err != nil {
+			http.Error(w, "Error encoding xml", http.StatusInternalServerError)
+
+			return
+		}
+
+		// Set the content type
+		w.Header().Set("Content-Type", XRDContentType)
+
+		// Write the response
+		_, _ = w.Write(data)
+	}))
+}
+
+// HostMetaJSONHandler serves the host-meta.json JRD document (RFC 6415). See
+// HostMetaHandler for how baseURL is used.
+func HostMetaJSONHandler(baseURL string, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	return o.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		// Set the content type
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write the response
+		if err := json.NewEncoder(w).Encode(hostMeta(baseURL, r)); err != nil {
+			http.Error(w, "Error encoding json", http.StatusInternalServerError)
+
+			return
+		}
+	}))
+}
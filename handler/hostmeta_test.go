@@ -0,0 +1,117 @@
+package handler_test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestHostMetaHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		baseURL      string
+		method       string
+		tls          bool
+		headers      map[string]string
+		wantCode     int
+		wantTemplate string
+	}{
+		{
+			name:         "uses the base URL",
+			baseURL:      "https://example.com/",
+			method:       http.MethodGet,
+			wantCode:     http.StatusOK,
+			wantTemplate: "https://example.com/.well-known/webfinger?resource={uri}",
+		},
+		{
+			name:         "builds the URL from the request",
+			method:       http.MethodGet,
+			wantCode:     http.StatusOK,
+			wantTemplate: "http://finger.example.com/.well-known/webfinger?resource={uri}",
+		},
+		{
+			name:         "uses https on TLS requests",
+			method:       http.MethodGet,
+			tls:          true,
+			wantCode:     http.StatusOK,
+			wantTemplate: "https://finger.example.com/.well-known/webfinger?resource={uri}",
+		},
+		{
+			name:         "ignores the forwarded scheme",
+			method:       http.MethodGet,
+			headers:      map[string]string{"X-Forwarded-Proto": "https"},
+			wantCode:     http.StatusOK,
+			wantTemplate: "http://finger.example.com/.well-known/webfinger?resource={uri}",
+		},
+		{
+			name:     "invalid method",
+			method:   http.MethodPost,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		newRequest := func(path string) *http.Request {
+			r := httptest.NewRequest(tc.method, "http://finger.example.com"+path, http.NoBody)
+			if tc.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			return r
+		}
+
+		t.Run(tc.name+" (xml)", func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			handler.HostMetaHandler(tc.baseURL).ServeHTTP(w, newRequest("/.well-known/host-meta"))
+
+			require.Equal(t, tc.wantCode, w.Code)
+
+			if tc.wantCode == http.StatusOK {
+				require.Equal(t, "application/xrd+xml", w.Header().Get("Content-Type"))
+				require.Contains(t, w.Body.String(), `<Link rel="lrdd" template="`+tc.wantTemplate+`">`)
+			}
+		})
+
+		t.Run(tc.name+" (json)", func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			handler.HostMetaJSONHandler(tc.baseURL).ServeHTTP(w, newRequest("/.well-known/host-meta.json"))
+
+			require.Equal(t, tc.wantCode, w.Code)
+
+			if tc.wantCode == http.StatusOK {
+				require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				require.NotContains(t, w.Body.String(), "subject")
+
+				got := &webfingers.WebFinger{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(got))
+
+				require.Equal(t, &webfingers.WebFinger{
+					Links: []webfingers.Link{
+						{Rel: handler.LRDDRel, Template: tc.wantTemplate},
+					},
+				}, got)
+			}
+		})
+	}
+}
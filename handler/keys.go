@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+// KeySetContentType is the content type of JWK Sets.
+const KeySetContentType = "application/jwk-set+json"
+
+// KeySetHandler serves the public keys of the signer as a JWK Set, so
+// clients can verify the webfingers it signed. Keys replaced in the signer
+// are served right away.
+func KeySetHandler(s *jws.Signer, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	return o.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		// Set the content type and caching headers
+		w.Header().Set("Content-Type", KeySetContentType)
+
+		if o.cacheMaxAge > 0 {
+			w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(o.cacheMaxAge.Seconds())))
+		}
+
+		// Write the response
+		if err := json.NewEncoder(w).Encode(s.KeySet()); err != nil {
+			http.Error(w, "Error encoding json", http.StatusInternalServerError)
+
+			return
+		}
+	}))
+}
@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+// newKey creates a new Ed25519 key.
+func newKey(t testing.TB) *jws.Key {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	k, err := jws.NewKey(priv, "")
+	require.NoError(t, err)
+
+	return k
+}
+
+// newSigner creates a signer with a new key.
+func newSigner(t testing.TB) *jws.Signer {
+	t.Helper()
+
+	s, err := jws.NewSigner(newKey(t))
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestKeySetHandler(t *testing.T) {
+	t.Parallel()
+
+	s := newSigner(t)
+	h := handler.KeySetHandler(s, handler.WithCacheMaxAge(time.Hour))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, handler.KeySetContentType, w.Header().Get("Content-Type"))
+	require.Equal(t, "max-age=3600", w.Header().Get("Cache-Control"))
+
+	var set jws.KeySet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, s.Keys()[0].ID, set.Keys[0].ID)
+	require.Empty(t, set.Keys[0].D)
+
+	// Rotated keys are served right away
+	require.NoError(t, s.SetKeys(newKey(t), s.Keys()[0]))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody))
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 2)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", http.NoBody))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
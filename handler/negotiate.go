@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// JRDContentType is the content type of JRD documents (RFC 7033).
+	JRDContentType = "application/jrd+json"
+	// XRDContentType is the content type of XRD 1.0 documents.
+	XRDContentType = "application/xrd+xml"
+	// JOSEContentType is the content type of JWS in the JSON serialization.
+	JOSEContentType = "application/jose+json"
+)
+
+// offer is a media type the handler can respond with, and the content type
+// sent when it is chosen.
+type offer struct {
+	mediaType   string
+	contentType string
+}
+
+// webfingerOffers are the media types webfingers are served as, from the
+// most to the least preferred.
+var webfingerOffers = []offer{ //nolint:gochecknoglobals // Read-only
+	{mediaType: JRDContentType, contentType: JRDContentType},
+	{mediaType: "application/json", contentType: JRDContentType},
+	{mediaType: XRDContentType, contentType: XRDContentType},
+	{mediaType: "application/xml", contentType: XRDContentType},
+	{mediaType: "text/xml", contentType: XRDContentType},
+}
+
+// signedOffers are the media types signed webfingers are served as. The JWS
+// is only sent to clients that ask for it.
+var signedOffers = append(slices.Clone(webfingerOffers), //nolint:gochecknoglobals // Read-only
+	offer{mediaType: JOSEContentType, contentType: JOSEContentType},
+)
+
+// acceptRange is a media range from an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity returns how specific the range is, or -1 if it doesn't match
+// the media type.
+func (a acceptRange) specificity(mediaType string) int {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	switch a.mediaType {
+	case mediaType:
+		return 2 //nolint:mnd // type/subtype
+	case typ + "/*":
+		return 1
+	case "*/*":
+		return 0
+	default:
+		return -1
+	}
+}
+
+// parseAccept parses the media ranges in an Accept header. Invalid ranges
+// are skipped.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	return ranges
+}
+
+// negotiate returns the offer that best matches the Accept header, and false
+// if none is acceptable. Each offer gets the quality of the most specific
+// range that matches it, and ties go to the earlier offer. Without a valid
+// Accept header, the first offer is chosen.
+func negotiate(accept string, offers []offer) (offer, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offers[0], true
+	}
+
+	var (
+		best  offer
+		bestQ float64
+	)
+
+	for _, o := range offers {
+		q, specificity := 0.0, -1
+
+		for _, r := range ranges {
+			if s := r.specificity(o.mediaType); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+
+	return best, bestQ > 0
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"git.maronato.dev/maronato/finger/jws"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// Option configures the webfinger handler.
+type Option func(*options)
+
+// options holds the configuration of the webfinger handler.
+type options struct {
+	cors        *CORSConfig
+	normalizer  webfingers.Normalizer
+	cacheMaxAge time.Duration
+	signer      *jws.Signer
+}
+
+// newOptions applies opts over the default options.
+func newOptions(opts []Option) *options {
+	o := &options{
+		normalizer: webfingers.DefaultNormalizer,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// wrap applies the options shared by every handler, such as CORS, to h.
+func (o *options) wrap(h http.Handler) http.Handler {
+	if o.cors != nil {
+		h = corsHandler(h, o.cors)
+	}
+
+	return h
+}
+
+// WithCORS enables CORS on the handler using the given config.
+func WithCORS(cfg CORSConfig) Option {
+	return func(o *options) {
+		o.cors = &cfg
+	}
+}
+
+// WithNormalizer sets the normalizer used on requested resources. The served
+// webfingers must be keyed by the same normalizer (see WebFingers.Normalized).
+func WithNormalizer(n webfingers.Normalizer) Option {
+	return func(o *options) {
+		o.normalizer = n
+	}
+}
+
+// WithCacheMaxAge sets how long clients may cache webfingers that don't set
+// their own max age. By default, no Cache-Control header is sent.
+func WithCacheMaxAge(maxAge time.Duration) Option {
+	return func(o *options) {
+		o.cacheMaxAge = maxAge
+	}
+}
+
+// WithSigner signs the webfingers served by the handler. Every response gets
+// a detached JWS of its body in the SignatureHeader header, and clients that
+// accept application/jose+json get the JRD wrapped in a signed JWS instead.
+func WithSigner(s *jws.Signer) Option {
+	return func(o *options) {
+		o.signer = s
+	}
+}
@@ -0,0 +1,411 @@
+// Package admin implements an HTTP API to manage the resources and URN
+// aliases in the finger files while the server is running.
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.yaml.in/yaml/v3"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+)
+
+const (
+	// ResourcesPath is the path of the resources endpoints.
+	ResourcesPath = "/resources"
+	// URNsPath is the path of the URN aliases endpoints.
+	URNsPath = "/urns"
+
+	// maxBodySize is the maximum size of request bodies.
+	maxBodySize = 1 << 20
+)
+
+var (
+	// ErrNotFound is returned when changing an entry that doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrExists is returned when creating an entry that already exists.
+	ErrExists = errors.New("already exists")
+	// ErrInvalid is returned when a change would make the files invalid.
+	ErrInvalid = errors.New("invalid change")
+	// ErrBadRequest is returned when a request body can't be used.
+	ErrBadRequest = errors.New("bad request")
+)
+
+// action is a change made through the API.
+type action string
+
+const (
+	actionCreate action = "create"
+	actionUpdate action = "update"
+	actionDelete action = "delete"
+)
+
+// kind is a kind of entry managed by the API.
+type kind struct {
+	name  string
+	route string
+	// path returns the file holding the entries.
+	path func(cfg *config.Config) string
+	// section is where the entries are in versioned files.
+	section string
+	// match reports whether a key in the file is the requested one.
+	match func(cfg *config.Config, key, requested string) bool
+	// check checks a new value.
+	check func(value any) error
+}
+
+//nolint:gochecknoglobals // Read-only
+var (
+	resources = kind{
+		name:    "resource",
+		route:   ResourcesPath,
+		path:    func(cfg *config.Config) string { return cfg.FingerPath },
+		section: "resources",
+		match:   sameResource,
+		check: func(value any) error {
+			if _, ok := value.(map[string]any); !ok {
+				return fmt.Errorf("%w: resources must be objects", ErrBadRequest)
+			}
+
+			return nil
+		},
+	}
+	urns = kind{
+		name:  "urn",
+		route: URNsPath,
+		path:  func(cfg *config.Config) string { return cfg.URNPath },
+		match: func(_ *config.Config, key, requested string) bool { return key == requested },
+		check: func(value any) error {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%w: URN aliases must be strings", ErrBadRequest)
+			}
+
+			return nil
+		},
+	}
+)
+
+// sameResource reports whether two resources are the same once normalized,
+// so "user@example.com" in the file matches "acct:user@example.com".
+func sameResource(cfg *config.Config, key, requested string) bool {
+	if key == requested {
+		return true
+	}
+
+	normalizer := cfg.Normalizer()
+	a, errA := normalizer.Normalize(key)
+	b, errB := normalizer.Normalize(requested)
+
+	return errA == nil && errB == nil && a == b
+}
+
+// API manages the URN and finger files. Changes are validated, written back
+// to the files and applied, and every change is recorded in the audit log.
+type API struct {
+	cfg   *config.Config
+	apply func(ctx context.Context) error
+	audit *slog.Logger
+
+	// mu serializes changes to the files.
+	mu sync.Mutex
+}
+
+// New creates an API that edits the files in cfg and calls apply after every
+// change, so it is served right away.
+func New(cfg *config.Config, apply func(ctx context.Context) error, audit *slog.Logger) *API {
+	return &API{
+		cfg:   cfg,
+		apply: apply,
+		audit: audit,
+	}
+}
+
+// Handler returns the API handler. Every request must have the token as a
+// bearer token.
+func (a *API) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	for _, k := range []kind{resources, urns} {
+		mux.HandleFunc("GET "+k.route, a.list(k))
+		mux.HandleFunc("GET "+k.route+"/{key...}", a.get(k))
+		mux.HandleFunc("POST "+k.route+"/{key...}", a.change(k, actionCreate))
+		mux.HandleFunc("PUT "+k.route+"/{key...}", a.change(k, actionUpdate))
+		mux.HandleFunc("DELETE "+k.route+"/{key...}", a.change(k, actionDelete))
+	}
+
+	return requireToken(token, mux)
+}
+
+// requireToken only lets requests with the bearer token through.
+func requireToken(token string, next http.Handler) http.Handler {
+	// Compare hashes so the comparison takes the same time for any length
+	want := sha256.Sum256([]byte(token))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		hash := sha256.Sum256([]byte(got))
+
+		if !ok || subtle.ConstantTimeCompare(hash[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="finger"`)
+			writeError(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized))) //nolint:err113 // Only used in the response
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// list returns every entry of the kind.
+func (a *API) list(k kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		a.mu.Lock()
+		doc, err := readDocument(k.path(a.cfg), k.section)
+		a.mu.Unlock()
+
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		entries := make(map[string]any)
+
+		for i := 0; i < len(doc.entries.Content); i += 2 {
+			if entries[doc.key(i)], err = decode(doc.value(i)); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// get returns an entry of the kind.
+func (a *API) get(k kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requested := r.PathValue("key")
+
+		a.mu.Lock()
+		doc, err := readDocument(k.path(a.cfg), k.section)
+		a.mu.Unlock()
+
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		i := doc.find(func(key string) bool { return k.match(a.cfg, key, requested) })
+		if i < 0 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s %s", ErrNotFound, k.name, requested))
+
+			return
+		}
+
+		value, err := decode(doc.value(i))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, value)
+	}
+}
+
+// change creates, updates or deletes an entry of the kind.
+func (a *API) change(k kind, act action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requested := r.PathValue("key")
+
+		var value any
+
+		if act != actionDelete {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&value); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %w", ErrBadRequest, err))
+
+				return
+			}
+
+			if err := k.check(value); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+
+				return
+			}
+		}
+
+		if err := a.edit(r.Context(), k, act, requested, value, r.RemoteAddr); err != nil {
+			writeError(w, errorStatus(err), err)
+
+			return
+		}
+
+		switch act {
+		case actionCreate:
+			writeJSON(w, http.StatusCreated, value)
+		case actionUpdate:
+			writeJSON(w, http.StatusOK, value)
+		case actionDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// edit changes an entry in its file, checks that the files are still valid,
+// writes the file and applies the change. Written changes are recorded in the
+// audit log along with the address of the client that made them.
+func (a *API) edit(ctx context.Context, k kind, act action, requested string, value any, remote string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	urnDoc, err := readDocument(a.cfg.URNPath, urns.section)
+	if err != nil {
+		return err
+	}
+
+	fingerDoc, err := readDocument(a.cfg.FingerPath, resources.section)
+	if err != nil {
+		return err
+	}
+
+	doc := fingerDoc
+	if k.name == urns.name {
+		doc = urnDoc
+	}
+
+	i := doc.find(func(key string) bool { return k.match(a.cfg, key, requested) })
+
+	switch {
+	case act == actionCreate && i >= 0:
+		return fmt.Errorf("%w: %s %s", ErrExists, k.name, doc.key(i))
+	case act != actionCreate && i < 0:
+		return fmt.Errorf("%w: %s %s", ErrNotFound, k.name, requested)
+	}
+
+	key := requested
+
+	var before any
+
+	if i >= 0 {
+		key = doc.key(i)
+
+		if before, err = decode(doc.value(i)); err != nil {
+			return err
+		}
+	}
+
+	if act == actionDelete {
+		doc.remove(i)
+	} else {
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return fmt.Errorf("%w: %w", ErrBadRequest, err)
+		}
+
+		doc.set(i, key, node)
+	}
+
+	if err := a.check(ctx, urnDoc, fingerDoc); err != nil {
+		return err
+	}
+
+	data, err := doc.bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(k.path(a.cfg), data); err != nil {
+		return err
+	}
+
+	a.audit.Info("Admin change",
+		slog.String("action", string(act)),
+		slog.String("kind", k.name),
+		slog.String("key", key),
+		slog.Any("before", before),
+		slog.Any("after", value),
+		slog.String("remote", remote),
+	)
+
+	if err := a.apply(ctx); err != nil {
+		return fmt.Errorf("error applying change: %w", err)
+	}
+
+	return nil
+}
+
+// check parses the files the same way the server does.
+func (a *API) check(ctx context.Context, urnDoc, fingerDoc *document) error {
+	f := fingerreader.NewFingerReader()
+
+	var err error
+
+	if f.URNSFile, err = urnDoc.bytes(); err != nil {
+		return err
+	}
+
+	if f.FingersFile, err = fingerDoc.bytes(); err != nil {
+		return err
+	}
+
+	fingers, err := f.ReadFingerFile(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	if _, err := fingers.Normalized(a.cfg.Normalizer()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	return nil
+}
+
+// decode decodes a YAML value so it can be encoded as JSON.
+func decode(node *yaml.Node) (any, error) {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error decoding value: %w", err)
+	}
+
+	return value, nil
+}
+
+// errorStatus returns the status code of an error.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value) //nolint:errcheck,errchkjson // Nothing to do if the client is gone
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,304 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/admin"
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/log"
+)
+
+const token = "secret"
+
+type testAPI struct {
+	cfg     *config.Config
+	handler http.Handler
+	audit   *strings.Builder
+	applied int
+}
+
+func newTestAPI(t *testing.T, urns, fingers string) *testAPI {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	cfg := config.NewConfig()
+	cfg.URNPath = filepath.Join(dir, "urns.yml")
+	cfg.FingerPath = filepath.Join(dir, "fingers.yml")
+
+	require.NoError(t, os.WriteFile(cfg.URNPath, []byte(urns), 0o600))
+	require.NoError(t, os.WriteFile(cfg.FingerPath, []byte(fingers), 0o600))
+
+	api := &testAPI{cfg: cfg, audit: &strings.Builder{}}
+
+	apply := func(context.Context) error {
+		api.applied++
+
+		return nil
+	}
+
+	api.handler = admin.New(cfg, apply, slog.New(slog.NewJSONHandler(api.audit, nil))).Handler(token)
+
+	return api
+}
+
+// do makes an authenticated request and returns the response status and body.
+func (a *testAPI) do(t *testing.T, method, path, body string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, a.cfg))
+	w := httptest.NewRecorder()
+
+	a.handler.ServeHTTP(w, req.WithContext(ctx))
+
+	return w.Code, w.Body.String()
+}
+
+func (a *testAPI) file(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestAPI_Auth(t *testing.T) {
+	t.Parallel()
+
+	api := newTestAPI(t, "", "")
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "missing token", header: "", want: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic " + token, want: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer " + token, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, admin.ResourcesPath, nil)
+			req.Header.Set("Authorization", tc.header)
+
+			w := httptest.NewRecorder()
+			api.handler.ServeHTTP(w, req)
+
+			require.Equal(t, tc.want, w.Code)
+		})
+	}
+}
+
+func TestAPI_Resources(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists and gets resources", func(t *testing.T) {
+		t.Parallel()
+
+		api := newTestAPI(t, "name: https://schema/name", "alice@example.com:\n  name: Alice\n")
+
+		status, body := api.do(t, http.MethodGet, admin.ResourcesPath, "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"alice@example.com": {"name": "Alice"}}`, body)
+
+		// Resources are matched once normalized
+		status, body = api.do(t, http.MethodGet, admin.ResourcesPath+"/acct:alice@example.com", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"name": "Alice"}`, body)
+
+		status, _ = api.do(t, http.MethodGet, admin.ResourcesPath+"/bob@example.com", "")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("matches resources with the configured normalizer", func(t *testing.T) {
+		t.Parallel()
+
+		api := newTestAPI(t, "", "Alice@example.com:\n  name: Alice\n")
+
+		status, _ := api.do(t, http.MethodGet, admin.ResourcesPath+"/alice@example.com", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		api.cfg.IgnoreCase = true
+
+		status, body := api.do(t, http.MethodGet, admin.ResourcesPath+"/alice@example.com", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"name": "Alice"}`, body)
+
+		// Resources can't be created twice with another case
+		status, _ = api.do(t, http.MethodPost, admin.ResourcesPath+"/ALICE@example.com", `{"name": "Alice"}`)
+		require.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("creates, updates and deletes resources", func(t *testing.T) {
+		t.Parallel()
+
+		api := newTestAPI(t, "", "# Our people\nalice@example.com:\n  name: Alice # The first one\n")
+
+		status, _ := api.do(t, http.MethodPost, admin.ResourcesPath+"/bob@example.com", `{"name": "Bob"}`)
+		require.Equal(t, http.StatusCreated, status)
+
+		status, _ = api.do(t, http.MethodPost, admin.ResourcesPath+"/bob@example.com", `{"name": "Bob"}`)
+		require.Equal(t, http.StatusConflict, status)
+
+		status, _ = api.do(t, http.MethodPut, admin.ResourcesPath+"/acct:alice@example.com", `{"name": "Alicia"}`)
+		require.Equal(t, http.StatusOK, status)
+
+		status, _ = api.do(t, http.MethodPut, admin.ResourcesPath+"/carol@example.com", `{"name": "Carol"}`)
+		require.Equal(t, http.StatusNotFound, status)
+
+		// Keys and comments are kept
+		fingers := api.file(t, api.cfg.FingerPath)
+		require.Contains(t, fingers, "# Our people\nalice@example.com:\n")
+		require.Contains(t, fingers, "Alicia")
+		require.Contains(t, fingers, "bob@example.com:\n  name: Bob\n")
+
+		status, _ = api.do(t, http.MethodDelete, admin.ResourcesPath+"/bob@example.com", "")
+		require.Equal(t, http.StatusNoContent, status)
+		require.NotContains(t, api.file(t, api.cfg.FingerPath), "bob@example.com")
+
+		require.Equal(t, 3, api.applied)
+		require.Equal(t, 3, strings.Count(api.audit.String(), "Admin change"))
+		require.Contains(t, api.audit.String(), `"action":"update","kind":"resource","key":"alice@example.com","before":{"name":"Alice"},"after":{"name":"Alicia"}`)
+	})
+
+	t.Run("rejects invalid changes", func(t *testing.T) {
+		t.Parallel()
+
+		original := "alice@example.com:\n  name: Alice\n  aliases: https://example.com/@alice\n"
+		api := newTestAPI(t, "", original)
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			want   int
+		}{
+			{
+				name:   "invalid links",
+				method: http.MethodPost,
+				path:   "/bob@example.com",
+				body:   `{"profile": {"rel": "profile", "href": "not a uri"}}`,
+				want:   http.StatusUnprocessableEntity,
+			},
+			{
+				name:   "duplicate aliases",
+				method: http.MethodPost,
+				path:   "/bob@example.com",
+				body:   `{"aliases": "https://example.com/@alice"}`,
+				want:   http.StatusUnprocessableEntity,
+			},
+			{
+				name:   "invalid resources",
+				method: http.MethodPost,
+				path:   "/https:%2F%2F",
+				body:   `{}`,
+				want:   http.StatusUnprocessableEntity,
+			},
+			{
+				name:   "values that are not objects",
+				method: http.MethodPut,
+				path:   "/alice@example.com",
+				body:   `"Alice"`,
+				want:   http.StatusBadRequest,
+			},
+			{
+				name:   "invalid JSON",
+				method: http.MethodPut,
+				path:   "/alice@example.com",
+				body:   `{`,
+				want:   http.StatusBadRequest,
+			},
+		}
+
+		for _, tc := range tests {
+			status, body := api.do(t, tc.method, admin.ResourcesPath+tc.path, tc.body)
+			require.Equal(t, tc.want, status, tc.name)
+
+			var resp map[string]string
+			require.NoError(t, json.Unmarshal([]byte(body), &resp), tc.name)
+			require.NotEmpty(t, resp["error"], tc.name)
+		}
+
+		// Nothing was written
+		require.Equal(t, original, api.file(t, api.cfg.FingerPath))
+		require.Zero(t, api.applied)
+		require.Empty(t, api.audit.String())
+	})
+
+	t.Run("edits URI resources", func(t *testing.T) {
+		t.Parallel()
+
+		api := newTestAPI(t, "", "")
+
+		status, _ := api.do(t, http.MethodPost, admin.ResourcesPath+"/https:%2F%2Fexample.com%2F@alice", `{"name": "Alice"}`)
+		require.Equal(t, http.StatusCreated, status)
+
+		status, body := api.do(t, http.MethodGet, admin.ResourcesPath+"/https:%2F%2FEXAMPLE.com%2F@alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"name": "Alice"}`, body)
+	})
+
+	t.Run("edits structured files", func(t *testing.T) {
+		t.Parallel()
+
+		api := newTestAPI(t, "", "version: 2\nresources:\n  alice@example.com:\n    properties:\n      name: Alice\n")
+
+		status, _ := api.do(t, http.MethodPost, admin.ResourcesPath+"/bob@example.com",
+			`{"links": [{"rel": "profile", "href": "https://example.com/bob"}]}`)
+		require.Equal(t, http.StatusCreated, status)
+
+		status, body := api.do(t, http.MethodGet, admin.ResourcesPath, "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{
+			"alice@example.com": {"properties": {"name": "Alice"}},
+			"bob@example.com": {"links": [{"rel": "profile", "href": "https://example.com/bob"}]}
+		}`, body)
+
+		require.Contains(t, api.file(t, api.cfg.FingerPath), "version: 2\n")
+	})
+}
+
+func TestAPI_URNs(t *testing.T) {
+	t.Parallel()
+
+	api := newTestAPI(t, "name: https://schema/name\n", "alice@example.com:\n  name: Alice\n")
+
+	status, _ := api.do(t, http.MethodPost, admin.URNsPath+"/nick", `"https://schema/nick"`)
+	require.Equal(t, http.StatusCreated, status)
+
+	status, body := api.do(t, http.MethodGet, admin.URNsPath, "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"name": "https://schema/name", "nick": "https://schema/nick"}`, body)
+
+	// URNs must be URIs
+	status, _ = api.do(t, http.MethodPut, admin.URNsPath+"/nick", `"not a uri"`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+
+	status, _ = api.do(t, http.MethodPut, admin.URNsPath+"/nick", `{"uri": "https://schema/nick"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = api.do(t, http.MethodDelete, admin.URNsPath+"/name", "")
+	require.Equal(t, http.StatusNoContent, status)
+	require.Equal(t, "nick: https://schema/nick\n", api.file(t, api.cfg.URNPath))
+}
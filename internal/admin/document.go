@@ -0,0 +1,201 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ErrInvalidDocument is returned when a file can't be edited, like when its
+// root is not a mapping.
+var ErrInvalidDocument = errors.New("invalid document")
+
+// document is a YAML file that is edited in place, keeping its comments and
+// the order of its entries.
+type document struct {
+	root *yaml.Node
+	// entries is the mapping holding the entries that are edited.
+	entries *yaml.Node
+}
+
+// readDocument reads the YAML file at path. Missing files are read as empty.
+// If section is set and the file has a version, the entries are read from
+// the section instead of the root, as in structured fingers files.
+func readDocument(path, section string) (*document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidDocument, path, err)
+	}
+
+	// Empty files have no document
+	if root.Kind == 0 {
+		root = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{newMapping()}}
+	}
+
+	entries := root.Content[0]
+	if isNull(entries) {
+		*entries = *newMapping()
+	}
+
+	if entries.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: %s is not a mapping", ErrInvalidDocument, path)
+	}
+
+	if section != "" && indexOf(entries, "version") >= 0 {
+		i := indexOf(entries, section)
+		if i < 0 {
+			entries.Content = append(entries.Content, newString(section), newMapping())
+			i = len(entries.Content) - 2
+		}
+
+		entries = entries.Content[i+1]
+		if isNull(entries) {
+			*entries = *newMapping()
+		}
+
+		if entries.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%w: %s of %s is not a mapping", ErrInvalidDocument, section, path)
+		}
+	}
+
+	return &document{root: root, entries: entries}, nil
+}
+
+// find returns the index of the first entry whose key matches, or -1.
+func (d *document) find(match func(key string) bool) int {
+	for i := 0; i < len(d.entries.Content); i += 2 {
+		if match(d.entries.Content[i].Value) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// key returns the key of the entry at i.
+func (d *document) key(i int) string {
+	return d.entries.Content[i].Value
+}
+
+// value returns the value of the entry at i.
+func (d *document) value(i int) *yaml.Node {
+	return d.entries.Content[i+1]
+}
+
+// set replaces the value of the entry at i, or adds a new entry if i is negative.
+func (d *document) set(i int, key string, value *yaml.Node) {
+	if i < 0 {
+		d.entries.Content = append(d.entries.Content, newString(key), value)
+
+		return
+	}
+
+	// Keep the comments of the replaced value
+	old := d.entries.Content[i+1]
+	value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+
+	d.entries.Content[i+1] = value
+}
+
+// remove removes the entry at i.
+func (d *document) remove(i int) {
+	d.entries.Content = append(d.entries.Content[:i], d.entries.Content[i+2:]...)
+}
+
+// bytes encodes the document.
+func (d *document) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2) //nolint:mnd // The indentation used in the examples
+
+	if err := enc.Encode(d.root); err != nil {
+		return nil, fmt.Errorf("error encoding document: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding document: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func newMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+func newString(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// indexOf returns the index of the key in a mapping node, or -1.
+func indexOf(mapping *yaml.Node, key string) int {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// writeFile replaces the file at path atomically, so readers never see a
+// partially written file. The file keeps its permissions if it exists.
+func writeFile(path string, data []byte) (err error) {
+	mode := os.FileMode(0o644) //nolint:mnd // The default permissions of new files
+
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	// The temporary file must be in the same directory to be renamed over the file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("error syncing temporary file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("error setting permissions: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -1,10 +1,17 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"git.maronato.dev/maronato/finger/webfingers"
 )
 
 const (
@@ -16,25 +23,110 @@ const (
 	DefaultURNPath = "urns.yml"
 	// DefaultFingerPath is the default file path to the webfinger definition file.
 	DefaultFingerPath = "fingers.yml"
+	// DefaultReloadInterval is the default interval between checks for changes in the files.
+	DefaultReloadInterval = 5 * time.Second
+	// DefaultCORSOrigins is the default comma-separated list of origins allowed to make cross-origin requests.
+	DefaultCORSOrigins = "*"
+	// DefaultTLSMinVersion is the default minimum TLS version.
+	DefaultTLSMinVersion = "1.2"
+	// DefaultRateLimitClients is the default number of clients tracked by the rate limiter.
+	DefaultRateLimitClients = 10000
+	// DefaultJWKSPath is the default path where the public signing keys are served.
+	DefaultJWKSPath = "/.well-known/jwks.json"
 )
 
+// TLSVersions maps the TLS versions that can be set as the minimum to their IDs.
+var TLSVersions = map[string]uint16{ //nolint:gochecknoglobals // Read-only
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
 // ErrInvalidConfig is returned when the config is invalid.
 var ErrInvalidConfig = errors.New("invalid config")
 
 type Config struct {
-	Debug      bool
-	Host       string
-	Port       string
-	URNPath    string
-	FingerPath string
+	Debug          bool
+	Host           string
+	Port           string
+	URNPath        string
+	FingerPath     string
+	ReloadInterval time.Duration
+	// IgnoreCase makes the local part of acct: resources case insensitive.
+	IgnoreCase bool
+	// CORSOrigins is a comma-separated list of allowed origins. CORS is disabled if empty.
+	CORSOrigins string
+	// CORSExposeHeaders is a comma-separated list of headers exposed to cross-origin clients.
+	CORSExposeHeaders string
+	// PublicURL is the URL where the server is reachable by clients, used to
+	// point to the webfinger endpoint from host-meta. If empty, it is built
+	// from each request.
+	PublicURL string
+	// CacheMaxAge is how long clients may cache webfingers that don't set
+	// their own max age. No Cache-Control header is sent if zero.
+	CacheMaxAge time.Duration
+	// Metrics enables the Prometheus metrics endpoint.
+	Metrics bool
+	// MetricsAddr is the address of a separate listener for the metrics
+	// endpoint. If empty, metrics are served by the main listener.
+	MetricsAddr string
+	// VHostsPath is the path to the virtual hosts file. If set, each host
+	// serves its own webfingers instead of the ones in FingerPath.
+	VHostsPath string
+	// VHostsDefault is the virtual host that serves requests to unknown
+	// hosts. If empty, they get no webfingers.
+	VHostsDefault string
+	// TLSCert and TLSKey are the paths to the certificate and key files. The
+	// server uses HTTPS if they are set.
+	TLSCert string
+	TLSKey  string
+	// TLSMinVersion is the minimum TLS version, one of TLSVersions.
+	TLSMinVersion string
+	// RedirectAddr is the address of a plain HTTP listener that redirects to
+	// HTTPS. It is disabled if empty.
+	RedirectAddr string
+	// Database is the path to a SQLite database holding more webfingers.
+	// Resources in the files take precedence over the ones in the database.
+	// It is disabled if empty, and can't be used with virtual hosts.
+	Database string
+	// AdminAddr is the address of the admin API listener. It is disabled if empty.
+	AdminAddr string
+	// AdminToken is the bearer token required by the admin API.
+	AdminToken string
+	// AdminAuditFile is the file where changes made through the admin API
+	// are recorded. If empty, they are logged with the server logs.
+	AdminAuditFile string
+	// RateLimitHits and RateLimitMisses are the number of webfinger hits and
+	// misses each client can make per minute. Zero disables the limit.
+	RateLimitHits   int
+	RateLimitMisses int
+	// RateLimitAllow is a comma-separated list of IPs and CIDRs that are not
+	// rate limited.
+	RateLimitAllow string
+	// RateLimitProxies is a comma-separated list of IPs and CIDRs of reverse
+	// proxies whose X-Forwarded-For header is trusted.
+	RateLimitProxies string
+	// RateLimitClients is the number of clients tracked by the rate limiter.
+	RateLimitClients int
+	// SigningKeys is a comma-separated list of key files. Webfingers are
+	// signed with the first key, and the public part of every key is
+	// published at JWKSPath. Signing is disabled if empty.
+	SigningKeys string
+	// JWKSPath is the path where the public signing keys are served.
+	JWKSPath string
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Host:       DefaultHost,
-		Port:       DefaultPort,
-		URNPath:    DefaultURNPath,
-		FingerPath: DefaultFingerPath,
+		Host:           DefaultHost,
+		Port:           DefaultPort,
+		URNPath:        DefaultURNPath,
+		FingerPath:     DefaultFingerPath,
+		ReloadInterval: DefaultReloadInterval,
+		CORSOrigins:    DefaultCORSOrigins,
+		TLSMinVersion:  DefaultTLSMinVersion,
+
+		RateLimitClients: DefaultRateLimitClients,
+		JWKSPath:         DefaultJWKSPath,
 	}
 }
 
@@ -42,6 +134,67 @@ func (c *Config) GetAddr() string {
 	return net.JoinHostPort(c.Host, c.Port)
 }
 
+// TLSEnabled reports whether the server uses HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Normalizer returns the normalizer used for resources.
+func (c *Config) Normalizer() webfingers.Normalizer {
+	return webfingers.Normalizer{IgnoreLocalCase: c.IgnoreCase}
+}
+
+// RateLimited reports whether webfinger requests are rate limited.
+func (c *Config) RateLimited() bool {
+	return c.RateLimitHits > 0 || c.RateLimitMisses > 0
+}
+
+// Signed reports whether webfingers are signed.
+func (c *Config) Signed() bool {
+	return len(SplitList(c.SigningKeys)) > 0
+}
+
+// ParsePrefixes parses a comma-separated list of IPs and CIDRs. IPs are
+// parsed as a single address prefix.
+func ParsePrefixes(list string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+
+	for _, item := range SplitList(list) {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing IP: %w", err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CIDR: %w", err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// SplitList splits a comma-separated list, ignoring empty items.
+func SplitList(list string) []string {
+	items := []string{}
+
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func (c *Config) Validate() error {
 	if c.Host == "" {
 		return fmt.Errorf("%w: host is empty", ErrInvalidConfig)
@@ -51,7 +204,8 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("%w: port is empty", ErrInvalidConfig)
 	}
 
-	if _, err := url.Parse(c.GetAddr()); err != nil {
+	// Parse the address as the authority of a URL, so IP hosts are valid too
+	if _, err := url.Parse("//" + c.GetAddr()); err != nil {
 		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
 	}
 
@@ -63,5 +217,95 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("%w: finger path is empty", ErrInvalidConfig)
 	}
 
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: public url must be an absolute URL", ErrInvalidConfig)
+		}
+	}
+
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("%w: reload interval is negative", ErrInvalidConfig)
+	}
+
+	if c.CacheMaxAge < 0 {
+		return fmt.Errorf("%w: cache max age is negative", ErrInvalidConfig)
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return fmt.Errorf("%w: metrics address: %w", ErrInvalidConfig, err)
+		}
+	}
+
+	if c.VHostsDefault != "" && c.VHostsPath == "" {
+		return fmt.Errorf("%w: a default virtual host requires a vhosts file", ErrInvalidConfig)
+	}
+
+	// The database would serve the resources of every domain on every host
+	if c.Database != "" && c.VHostsPath != "" {
+		return fmt.Errorf("%w: the database can't be used with virtual hosts", ErrInvalidConfig)
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("%w: tls cert and key must be set together", ErrInvalidConfig)
+	}
+
+	if _, ok := TLSVersions[c.TLSMinVersion]; c.TLSMinVersion != "" && !ok {
+		return fmt.Errorf("%w: unsupported tls min version %q", ErrInvalidConfig, c.TLSMinVersion)
+	}
+
+	if c.RedirectAddr != "" {
+		if !c.TLSEnabled() {
+			return fmt.Errorf("%w: redirecting to https requires tls", ErrInvalidConfig)
+		}
+
+		if _, _, err := net.SplitHostPort(c.RedirectAddr); err != nil {
+			return fmt.Errorf("%w: redirect address: %w", ErrInvalidConfig, err)
+		}
+	}
+
+	if c.RateLimitHits < 0 || c.RateLimitMisses < 0 {
+		return fmt.Errorf("%w: rate limits are negative", ErrInvalidConfig)
+	}
+
+	if c.RateLimitClients < 0 {
+		return fmt.Errorf("%w: rate limit clients is negative", ErrInvalidConfig)
+	}
+
+	if _, err := ParsePrefixes(c.RateLimitAllow); err != nil {
+		return fmt.Errorf("%w: rate limit allowlist: %w", ErrInvalidConfig, err)
+	}
+
+	if _, err := ParsePrefixes(c.RateLimitProxies); err != nil {
+		return fmt.Errorf("%w: rate limit proxies: %w", ErrInvalidConfig, err)
+	}
+
+	if c.Signed() && !strings.HasPrefix(c.JWKSPath, "/") {
+		return fmt.Errorf("%w: jwks path must start with /", ErrInvalidConfig)
+	}
+
+	if c.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			return fmt.Errorf("%w: admin address: %w", ErrInvalidConfig, err)
+		}
+
+		if c.AdminToken == "" {
+			return fmt.Errorf("%w: the admin api requires a token", ErrInvalidConfig)
+		}
+
+		if c.VHostsPath != "" {
+			return fmt.Errorf("%w: the admin api can't be used with virtual hosts", ErrInvalidConfig)
+		}
+
+		// The admin API edits the fingers file in place
+		if len(SplitList(c.FingerPath)) != 1 || strings.ContainsAny(c.FingerPath, "*?[") {
+			return fmt.Errorf("%w: the admin api requires a single fingers file", ErrInvalidConfig)
+		}
+
+		if info, err := os.Stat(c.FingerPath); err == nil && info.IsDir() {
+			return fmt.Errorf("%w: the admin api requires a single fingers file, not a directory", ErrInvalidConfig)
+		}
+	}
+
 	return nil
 }
@@ -1,7 +1,10 @@
 package config_test
 
 import (
+	"net/netip"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -79,6 +82,203 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "ip host",
+			cfg: &config.Config{
+				Host:       "0.0.0.0",
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+			},
+			wantErr: false,
+		},
+		{
+			name: "non-numeric port",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       "http",
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+			},
+			wantErr: true,
+		},
+		{
+			name: "default virtual host without vhosts file",
+			cfg: &config.Config{
+				Host:          config.DefaultHost,
+				Port:          config.DefaultPort,
+				URNPath:       config.DefaultURNPath,
+				FingerPath:    config.DefaultFingerPath,
+				VHostsDefault: "example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "database with virtual hosts",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				VHostsPath: "vhosts.yml",
+				Database:   "finger.db",
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls cert without key",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				TLSCert:    "cert.pem",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported tls min version",
+			cfg: &config.Config{
+				Host:          config.DefaultHost,
+				Port:          config.DefaultPort,
+				URNPath:       config.DefaultURNPath,
+				FingerPath:    config.DefaultFingerPath,
+				TLSCert:       "cert.pem",
+				TLSKey:        "key.pem",
+				TLSMinVersion: "1.0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "redirect without tls",
+			cfg: &config.Config{
+				Host:         config.DefaultHost,
+				Port:         config.DefaultPort,
+				URNPath:      config.DefaultURNPath,
+				FingerPath:   config.DefaultFingerPath,
+				RedirectAddr: ":80",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid tls",
+			cfg: &config.Config{
+				Host:          config.DefaultHost,
+				Port:          config.DefaultPort,
+				URNPath:       config.DefaultURNPath,
+				FingerPath:    config.DefaultFingerPath,
+				TLSCert:       "cert.pem",
+				TLSKey:        "key.pem",
+				TLSMinVersion: "1.3",
+				RedirectAddr:  ":80",
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative rate limit",
+			cfg: &config.Config{
+				Host:          config.DefaultHost,
+				Port:          config.DefaultPort,
+				URNPath:       config.DefaultURNPath,
+				FingerPath:    config.DefaultFingerPath,
+				RateLimitHits: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rate limit allowlist",
+			cfg: &config.Config{
+				Host:           config.DefaultHost,
+				Port:           config.DefaultPort,
+				URNPath:        config.DefaultURNPath,
+				FingerPath:     config.DefaultFingerPath,
+				RateLimitAllow: "10.0.0.0/8, localhost",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rate limit proxies",
+			cfg: &config.Config{
+				Host:             config.DefaultHost,
+				Port:             config.DefaultPort,
+				URNPath:          config.DefaultURNPath,
+				FingerPath:       config.DefaultFingerPath,
+				RateLimitProxies: "10.0.0.0/33",
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative jwks path",
+			cfg: &config.Config{
+				Host:        config.DefaultHost,
+				Port:        config.DefaultPort,
+				URNPath:     config.DefaultURNPath,
+				FingerPath:  config.DefaultFingerPath,
+				SigningKeys: "key.pem",
+				JWKSPath:    "jwks.json",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin api without token",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				AdminAddr:  ":9091",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin api with virtual hosts",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				VHostsPath: "vhosts.yml",
+				AdminAddr:  ":9091",
+				AdminToken: "secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin api with many fingers files",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: "fingers.d/*.yml",
+				AdminAddr:  ":9091",
+				AdminToken: "secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin api with a fingers directory",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: os.TempDir(),
+				AdminAddr:  ":9091",
+				AdminToken: "secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid admin api",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				AdminAddr:  "localhost:9091",
+				AdminToken: "secret",
+			},
+			wantErr: false,
+		},
 		{
 			name: "empty urn path",
 			cfg: &config.Config{
@@ -98,6 +298,72 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative reload interval",
+			cfg: &config.Config{
+				Host:           config.DefaultHost,
+				Port:           config.DefaultPort,
+				URNPath:        config.DefaultURNPath,
+				FingerPath:     config.DefaultFingerPath,
+				ReloadInterval: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative public url",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				PublicURL:  "example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative cache max age",
+			cfg: &config.Config{
+				Host:        config.DefaultHost,
+				Port:        config.DefaultPort,
+				URNPath:     config.DefaultURNPath,
+				FingerPath:  config.DefaultFingerPath,
+				CacheMaxAge: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metrics address",
+			cfg: &config.Config{
+				Host:        config.DefaultHost,
+				Port:        config.DefaultPort,
+				URNPath:     config.DefaultURNPath,
+				FingerPath:  config.DefaultFingerPath,
+				MetricsAddr: "9090",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid metrics address",
+			cfg: &config.Config{
+				Host:        config.DefaultHost,
+				Port:        config.DefaultPort,
+				URNPath:     config.DefaultURNPath,
+				FingerPath:  config.DefaultFingerPath,
+				MetricsAddr: ":9090",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid public url",
+			cfg: &config.Config{
+				Host:       config.DefaultHost,
+				Port:       config.DefaultPort,
+				URNPath:    config.DefaultURNPath,
+				FingerPath: config.DefaultFingerPath,
+				PublicURL:  "https://example.com",
+			},
+			wantErr: false,
+		},
 		{
 			name: "valid",
 			cfg: &config.Config{
@@ -120,3 +386,101 @@ func TestConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{
+			name: "empty",
+			list: "",
+			want: []string{},
+		},
+		{
+			name: "single item",
+			list: "*",
+			want: []string{"*"},
+		},
+		{
+			name: "multiple items",
+			list: "https://a.example.com, https://b.example.com,,",
+			want: []string{"https://a.example.com", "https://b.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, config.SplitList(tc.list))
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		list    string
+		want    []netip.Prefix
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			list: "",
+			want: []netip.Prefix{},
+		},
+		{
+			name: "IPs and CIDRs",
+			list: "192.0.2.1, 10.1.0.0/8, 2001:db8::/32",
+			want: []netip.Prefix{
+				netip.MustParsePrefix("192.0.2.1/32"),
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("2001:db8::/32"),
+			},
+		},
+		{
+			name:    "invalid IP",
+			list:    "example.com",
+			wantErr: true,
+		},
+		{
+			name:    "invalid CIDR",
+			list:    "10.0.0.0/33",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := config.ParsePrefixes(tc.list)
+			if tc.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestConfig_Normalizer(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewConfig()
+	require.False(t, cfg.Normalizer().IgnoreLocalCase)
+
+	cfg.IgnoreCase = true
+	require.True(t, cfg.Normalizer().IgnoreLocalCase)
+}
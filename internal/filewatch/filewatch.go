@@ -0,0 +1,57 @@
+// Package filewatch tells when files change on disk, so they can be reloaded.
+package filewatch
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// fileState is what is used to tell if a file changed.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// States holds the state of files at some point, keyed by their paths.
+type States map[string]fileState
+
+// Stat returns the current state of each file. Missing files are a valid
+// state too, since files can be optional. Files that can't be read for
+// another reason are reported by whatever reads them.
+//
+// Take the states before reading the files, so changes made while they are
+// being read are picked up by the next check.
+func Stat(paths ...string) States {
+	states := make(States, len(paths))
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			states[path] = fileState{exists: !errors.Is(err, os.ErrNotExist)}
+
+			continue
+		}
+
+		states[path] = fileState{
+			exists:  true,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		}
+	}
+
+	return states
+}
+
+// Changed reports whether any of the files changed since the states were
+// taken.
+func (s States) Changed(paths ...string) bool {
+	for path, state := range Stat(paths...) {
+		if s[path] != state {
+			return true
+		}
+	}
+
+	return false
+}
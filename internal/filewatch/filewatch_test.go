@@ -0,0 +1,44 @@
+package filewatch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/filewatch"
+)
+
+func TestStates_Changed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.yml")
+	missing := filepath.Join(dir, "missing.yml")
+
+	require.NoError(t, os.WriteFile(file, []byte("a: b"), 0o600))
+
+	states := filewatch.Stat(file, missing)
+	require.False(t, states.Changed(file, missing))
+
+	// Changes in size and modification time are detected
+	require.NoError(t, os.WriteFile(file, []byte("a: c"), 0o600))
+	require.NoError(t, os.Chtimes(file, time.Time{}, time.Now().Add(time.Hour)))
+	require.True(t, states.Changed(file))
+
+	states = filewatch.Stat(file, missing)
+	require.NoError(t, os.WriteFile(file, []byte("a: bc"), 0o600))
+	require.True(t, states.Changed(file))
+
+	// So are created and removed files
+	states = filewatch.Stat(file, missing)
+	require.NoError(t, os.WriteFile(missing, []byte("a: b"), 0o600))
+	require.True(t, states.Changed(missing))
+
+	states = filewatch.Stat(file, missing)
+	require.NoError(t, os.Remove(file))
+	require.True(t, states.Changed(file))
+	require.False(t, states.Changed(missing))
+}
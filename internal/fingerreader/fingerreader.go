@@ -2,10 +2,14 @@ package fingerreader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"go.yaml.in/yaml/v3"
 
@@ -14,9 +18,23 @@ import (
 	"git.maronato.dev/maronato/finger/webfingers"
 )
 
+// ErrInvalidFingersFile is returned when the fingers file has an invalid structure.
+var ErrInvalidFingersFile = errors.New("invalid fingers file")
+
 type FingerReader struct {
-	URNSFile    []byte
+	URNSFile []byte
+	// FingersFile is a fingers file that was not read from disk, like the
+	// ones given inline.
 	FingersFile []byte
+	// FingersFiles are the fingers files read from disk. Their resources are
+	// merged with the ones in FingersFile.
+	FingersFiles []File
+}
+
+// File is a file and the path it was read from.
+type File struct {
+	Path string
+	Data []byte
 }
 
 func NewFingerReader() *FingerReader {
@@ -25,42 +43,146 @@ func NewFingerReader() *FingerReader {
 
 func (f *FingerReader) ReadFiles(cfg *config.Config) error {
 	// Read URNs file
-	file, err := os.ReadFile(cfg.URNPath)
+	file, err := readFile(cfg.URNPath, config.DefaultURNPath)
 	if err != nil {
-		// If the file does not exist and the path is the default, set the URNs to an empty map
-		if os.IsNotExist(err) && cfg.URNPath == config.DefaultURNPath {
-			f.URNSFile = []byte("")
-		} else {
-			return fmt.Errorf("error opening URNs file: %w", err)
-		}
+		return fmt.Errorf("error opening URNs file: %w", err)
 	}
 
 	f.URNSFile = file
 
-	// Read fingers file
-	file, err = os.ReadFile(cfg.FingerPath)
+	// Read fingers files
+	paths, err := FingerFiles(cfg)
 	if err != nil {
-		// If the file does not exist and the path is the default, set the fingers to an empty map
-		if os.IsNotExist(err) && cfg.FingerPath == config.DefaultFingerPath {
-			f.FingersFile = []byte("")
-		} else {
+		return fmt.Errorf("error opening fingers file: %w", err)
+	}
+
+	f.FingersFiles = make([]File, 0, len(paths))
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
 			return fmt.Errorf("error opening fingers file: %w", err)
 		}
-	}
 
-	f.FingersFile = file
+		f.FingersFiles = append(f.FingersFiles, File{Path: path, Data: data})
+	}
 
 	return nil
 }
 
+// FingerFiles returns the fingers files in cfg, whose FingerPath is a
+// comma-separated list of files, directories and globs. Directories hold the
+// .yml and .yaml files directly inside them, and globs only match files.
+// Hidden files in directories and globs are skipped, and so is the default
+// fingers file if it doesn't exist.
+func FingerFiles(cfg *config.Config) ([]string, error) {
+	var files []string
+
+	for _, path := range config.SplitList(cfg.FingerPath) {
+		if strings.ContainsAny(path, "*?[") {
+			matches, err := globFiles(path)
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, matches...)
+
+			continue
+		}
+
+		info, err := os.Stat(path)
+
+		switch {
+		case os.IsNotExist(err) && path == config.DefaultFingerPath:
+			continue
+		case err != nil:
+			return nil, err //nolint:wrapcheck // Wrapped by the callers
+		case info.IsDir():
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err //nolint:wrapcheck // Wrapped by the callers
+			}
+
+			for _, entry := range entries {
+				name := entry.Name()
+				ext := filepath.Ext(name)
+
+				// Skip hidden files, like the ones left by editors
+				if !entry.IsDir() && !strings.HasPrefix(name, ".") && (ext == ".yml" || ext == ".yaml") {
+					files = append(files, filepath.Join(path, name))
+				}
+			}
+		default:
+			files = append(files, path)
+		}
+	}
+
+	// Files matched more than once are only read once
+	seen := make(map[string]bool, len(files))
+
+	return slices.DeleteFunc(files, func(path string) bool {
+		clean := filepath.Clean(path)
+		if seen[clean] {
+			return true
+		}
+
+		seen[clean] = true
+
+		return false
+	}), nil
+}
+
+// globFiles returns the files matching the glob pattern, in lexical order.
+// Hidden files are skipped, as they are in shells.
+func globFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error matching %s: %w", pattern, err)
+	}
+
+	files := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		if strings.HasPrefix(filepath.Base(match), ".") {
+			continue
+		}
+
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+
+	return files, nil
+}
+
+// readFile reads the file at path. If the file does not exist and the path
+// is the default one, it is read as empty.
+func readFile(path, defaultPath string) ([]byte, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && path == defaultPath {
+			return []byte(""), nil
+		}
+
+		return nil, err //nolint:wrapcheck // Wrapped by the callers
+	}
+
+	return file, nil
+}
+
 func (f *FingerReader) ReadFingerFile(ctx context.Context) (webfingers.WebFingers, error) {
 	l := log.FromContext(ctx)
 
 	urnAliases := make(webfingers.URNAliases)
-	resources := make(webfingers.Resources)
+
+	// Expand the variables before parsing the file
+	urnsFile, err := expandFile(f.URNSFile, func(*yaml.Node) locator { return urnLocation })
+	if err != nil {
+		return nil, fmt.Errorf("error expanding URNs file: %w", err)
+	}
 
 	// Parse the URNs file
-	if err := yaml.Unmarshal(f.URNSFile, &urnAliases); err != nil {
+	if err := yaml.Unmarshal(urnsFile, &urnAliases); err != nil {
 		return nil, fmt.Errorf("error unmarshalling URNs file: %w", err)
 	}
 
@@ -71,20 +193,92 @@ func (f *FingerReader) ReadFingerFile(ctx context.Context) (webfingers.WebFinger
 		}
 	}
 
-	l.Debug("URNs file parsed successfully", slog.Int("number", len(urnAliases)), slog.Any("data", urnAliases))
+	// Only log counts, since values can hold expanded secrets
+	l.Debug("URNs file parsed successfully", slog.Int("number", len(urnAliases)))
 
-	// Parse the fingers file
-	if err := yaml.Unmarshal(f.FingersFile, &resources); err != nil {
-		return nil, fmt.Errorf("error unmarshalling fingers file: %w", err)
+	// Parse the fingers files
+	files := f.FingersFiles
+	if f.FingersFile != nil {
+		files = append([]File{{Data: f.FingersFile}}, files...)
+	}
+
+	descriptors, err := mergeFingers(files)
+	if err != nil {
+		return nil, err
 	}
 
-	l.Debug("Fingers file parsed successfully", slog.Int("number", len(resources)), slog.Any("data", resources))
+	l.Debug(
+		"Fingers files parsed successfully",
+		slog.Int("files", len(files)),
+		slog.Int("number", len(descriptors)),
+	)
 
 	// Parse raw data
-	fingers, err := webfingers.NewWebFingers(resources, urnAliases)
+	fingers, err := webfingers.NewWebFingersFromDescriptors(descriptors, urnAliases)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing raw fingers: %w", err)
 	}
 
 	return fingers, nil
 }
+
+// mergeFingers parses the fingers files and merges their resources. Defaults
+// and templates only apply to the file they are in, and a resource can only
+// be in one file.
+func mergeFingers(files []File) (webfingers.Descriptors, error) {
+	descriptors := make(webfingers.Descriptors)
+
+	// source is where a resource was found, and name describes it in errors.
+	type source struct{ path, name string }
+
+	// sources holds where each normalized subject and alias was found
+	sources := make(map[string]source)
+
+	for _, file := range files {
+		fileDescriptors, err := file.parseFingers()
+		if err != nil {
+			if file.Path != "" {
+				return nil, fmt.Errorf("%s: %w", file.Path, err)
+			}
+
+			return nil, err
+		}
+
+		for subject, descriptor := range fileDescriptors {
+			src := source{path: file.Path, name: file.Path}
+
+			for i, resource := range append([]string{subject}, descriptor.Aliases...) {
+				if i > 0 {
+					src.name = fmt.Sprintf("%s (alias of %s)", file.Path, subject)
+				}
+
+				// Invalid resources are reported when creating the webfingers
+				key, err := webfingers.Normalize(resource)
+				if err != nil {
+					key = resource
+				}
+
+				// Duplicates in the same file are reported with the rest
+				if other, ok := sources[key]; ok && other.path != file.Path {
+					return nil, fmt.Errorf("%w: %s is in both %s and %s", webfingers.ErrDuplicateResource, resource, other.name, src.name)
+				}
+
+				sources[key] = src
+			}
+
+			descriptors[subject] = descriptor
+		}
+	}
+
+	return descriptors, nil
+}
+
+// parseFingers expands the variables in the fingers file and parses it.
+func (f File) parseFingers() (webfingers.Descriptors, error) {
+	data, err := expandFile(f.Data, fingersLocation)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding fingers file: %w", err)
+	}
+
+	return parseFingers(data)
+}
@@ -3,6 +3,7 @@ package fingerreader_test
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -108,12 +109,184 @@ func TestFingerReader_ReadFiles(t *testing.T) {
 			} else {
 				require.NoError(t, err)
 				require.Equal(t, []byte(tc.urnsContent), f.URNSFile)
-				require.Equal(t, []byte(tc.fingersContent), f.FingersFile)
+				require.Equal(t, []fingerreader.File{{Path: fingersFileName, Data: []byte(tc.fingersContent)}}, f.FingersFiles)
 			}
 		})
 	}
 }
 
+func TestFingerFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "fingers.d")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(confDir, "nested.yml"), 0o700))
+
+	for _, name := range []string{"fingers.yml", "fingers.d/b.yaml", "fingers.d/a.yml", "fingers.d/.hidden.yml", "fingers.d/notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "reads a file",
+			path: filepath.Join(dir, "fingers.yml"),
+			want: []string{filepath.Join(dir, "fingers.yml")},
+		},
+		{
+			name: "reads the yaml files in directories",
+			path: confDir,
+			want: []string{filepath.Join(confDir, "a.yml"), filepath.Join(confDir, "b.yaml")},
+		},
+		{
+			name: "reads the files matching globs",
+			path: filepath.Join(dir, "*", "*.y*ml"),
+			want: []string{filepath.Join(confDir, "a.yml"), filepath.Join(confDir, "b.yaml")},
+		},
+		{
+			name: "reads lists once",
+			path: filepath.Join(dir, "fingers.yml") + ", " + confDir + "," + filepath.Join(confDir, "a.yml"),
+			want: []string{filepath.Join(dir, "fingers.yml"), filepath.Join(confDir, "a.yml"), filepath.Join(confDir, "b.yaml")},
+		},
+		{
+			name: "reads nothing for globs matching nothing",
+			path: filepath.Join(dir, "*.json"),
+		},
+		{
+			name: "skips the default file if missing",
+			path: config.DefaultFingerPath,
+		},
+		{
+			name:    "errors on missing files",
+			path:    filepath.Join(dir, "missing.yml"),
+			wantErr: true,
+		},
+		{
+			name:    "errors on invalid globs",
+			path:    filepath.Join(dir, "["),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.NewConfig()
+			cfg.FingerPath = tc.path
+
+			got, err := fingerreader.FingerFiles(cfg)
+			if tc.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestReadFingerFile_MultipleFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, config.NewConfig()))
+
+	engineering := fingerreader.File{
+		Path: "fingers.d/engineering.yml",
+		Data: []byte("defaults:\n  team: Engineering\nalice@example.com:\n  name: Alice"),
+	}
+	sales := fingerreader.File{
+		Path: "fingers.d/sales.yml",
+		Data: []byte("bob@example.com:\n  name: Bob"),
+	}
+
+	t.Run("merges the files", func(t *testing.T) {
+		t.Parallel()
+
+		f := fingerreader.NewFingerReader()
+		f.FingersFiles = []fingerreader.File{engineering, sales}
+
+		fingers, err := f.ReadFingerFile(ctx)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"name": "Alice", "team": "Engineering"}, fingers["acct:alice@example.com"].Properties)
+
+		// Defaults only apply to their own file
+		require.Equal(t, map[string]string{"name": "Bob"}, fingers["acct:bob@example.com"].Properties)
+	})
+
+	t.Run("reports duplicates across files", func(t *testing.T) {
+		t.Parallel()
+
+		f := fingerreader.NewFingerReader()
+		f.FingersFiles = []fingerreader.File{
+			engineering,
+			{Path: "fingers.d/sales.yml", Data: []byte("acct:alice@EXAMPLE.com:\n  name: Alice")},
+		}
+
+		_, err := f.ReadFingerFile(ctx)
+		require.ErrorIs(t, err, webfingers.ErrDuplicateResource)
+		require.ErrorContains(t, err, "fingers.d/engineering.yml and fingers.d/sales.yml")
+	})
+
+	t.Run("reports duplicate aliases across files", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name  string
+			sales string
+			want  string
+		}{
+			{
+				name:  "alias of a subject",
+				sales: "bob@example.com:\n  aliases: acct:alice@example.com",
+				want:  "fingers.d/engineering.yml and fingers.d/sales.yml (alias of bob@example.com)",
+			},
+			{
+				name:  "subject of an alias",
+				sales: "https://example.com/@alice:\n  name: Alice",
+				want:  "fingers.d/engineering.yml (alias of alice@example.com) and fingers.d/sales.yml",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				f := fingerreader.NewFingerReader()
+				f.FingersFiles = []fingerreader.File{
+					{Path: "fingers.d/engineering.yml", Data: []byte("alice@example.com:\n  aliases: https://example.com/@alice")},
+					{Path: "fingers.d/sales.yml", Data: []byte(tc.sales)},
+				}
+
+				_, err := f.ReadFingerFile(ctx)
+				require.ErrorIs(t, err, webfingers.ErrDuplicateResource)
+				require.ErrorContains(t, err, tc.want)
+			})
+		}
+	})
+
+	t.Run("names the file with errors", func(t *testing.T) {
+		t.Parallel()
+
+		f := fingerreader.NewFingerReader()
+		f.FingersFiles = []fingerreader.File{engineering, {Path: "fingers.d/sales.yml", Data: []byte("invalid")}}
+
+		_, err := f.ReadFingerFile(ctx)
+		require.ErrorContains(t, err, "fingers.d/sales.yml: ")
+	})
+}
+
 func TestReadFingerFile(t *testing.T) {
 	t.Parallel()
 
@@ -163,6 +336,112 @@ func TestReadFingerFile(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:           "reads aliases lists",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  name: John Doe\n  aliases:\n    - https://example.com/@user\n    - https://example.com/users/user",
+			returns: func() webfingers.WebFingers {
+				finger := &webfingers.WebFinger{
+					Subject: "acct:user@example.com",
+					Aliases: []string{"https://example.com/@user", "https://example.com/users/user"},
+					Properties: map[string]string{
+						"https://schema/name": "John Doe",
+					},
+				}
+
+				return webfingers.WebFingers{
+					"acct:user@example.com":          finger,
+					"https://example.com/@user":      finger,
+					"https://example.com/users/user": finger,
+				}
+			}(),
+			wantErr: false,
+		},
+		{
+			name:           "errors on invalid aliases",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  aliases:\n    nested: map",
+			wantErr:        true,
+		},
+		{
+			name:        "reads link objects",
+			urnsContent: "subscribe: http://ostatus.org/schema/1.0/subscribe",
+			fingersContent: `user@example.com:
+  self:
+    type: application/activity+json
+    href: https://example.com/users/user
+    titles:
+      en-us: User
+    properties:
+      https://example.com/ns/prop: value
+  subscribe:
+    template: https://example.com/authorize?uri={uri}
+`,
+			returns: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					Links: []webfingers.Link{
+						{
+							Rel:  "self",
+							Type: "application/activity+json",
+							Href: "https://example.com/users/user",
+							Titles: map[string]string{
+								"en-us": "User",
+							},
+							Properties: map[string]string{
+								"https://example.com/ns/prop": "value",
+							},
+						},
+						{
+							Rel:      "http://ostatus.org/schema/1.0/subscribe",
+							Template: "https://example.com/authorize?uri={uri}",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "inherits defaults and templates",
+			urnsContent: "name: https://schema/name\nopenid: http://openid.net/specs/connect/1.0/issuer",
+			fingersContent: `defaults:
+  openid: https://auth.example.com
+  organization: Example
+.avatar:
+  avatar:
+    type: image/png
+    href: https://avatars.example.com/user.png
+user@example.com:
+  extends: [.avatar]
+  unset: organization
+  name: John Doe
+`,
+			returns: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					Links: []webfingers.Link{
+						{Rel: "http://openid.net/specs/connect/1.0/issuer", Href: "https://auth.example.com"},
+						{Rel: "avatar", Type: "image/png", Href: "https://avatars.example.com/user.png"},
+					},
+					Properties: map[string]string{
+						"https://schema/name": "John Doe",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:           "errors on cyclic templates",
+			urnsContent:    "",
+			fingersContent: ".a:\n  extends: .b\n.b:\n  extends: .a\nuser@example.com:\n  extends: .a",
+			wantErr:        true,
+		},
+		{
+			name:           "errors on invalid link hrefs",
+			urnsContent:    "",
+			fingersContent: "user@example.com:\n  self:\n    href: invalid",
+			wantErr:        true,
+		},
 		{
 			name:           "errors on invalid URNs file",
 			urnsContent:    "invalid",
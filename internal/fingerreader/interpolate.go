@@ -0,0 +1,221 @@
+package fingerreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	// ErrMissingVariable is returned when a file uses an environment variable
+	// that is not set and has no default.
+	ErrMissingVariable = errors.New("missing variable")
+	// ErrInvalidVariable is returned when a file has a malformed ${...} expression.
+	ErrInvalidVariable = errors.New("invalid variable")
+)
+
+// variableName matches the names of environment variables.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`) //nolint:gochecknoglobals // Read-only
+
+// locator describes where a value of a file is from its path, like
+// "resource user@example.com, key name".
+type locator func(path []string) string
+
+// urnLocation describes where a value of the URNs file is.
+func urnLocation(path []string) string {
+	return "key " + joinPath(path)
+}
+
+// fingersLocation returns the locator of a fingers file, whose resources are
+// its root keys or the keys under "resources" in the structured schema.
+func fingersLocation(root *yaml.Node) locator {
+	header := fileHeader{}
+	structured := root.Decode(&header) == nil && header.Version != 0
+
+	return func(path []string) string {
+		if structured {
+			if len(path) == 0 || path[0] != "resources" {
+				return "key " + joinPath(path)
+			}
+
+			path = path[1:]
+		}
+
+		switch len(path) {
+		case 0:
+			return ""
+		case 1:
+			return "resource " + path[0]
+		default:
+			return "resource " + path[0] + ", key " + joinPath(path[1:])
+		}
+	}
+}
+
+// joinPath joins the keys of a path, like "links[0].href".
+func joinPath(path []string) string {
+	b := strings.Builder{}
+
+	for i, key := range path {
+		if i > 0 && !strings.HasPrefix(key, "[") {
+			b.WriteByte('.')
+		}
+
+		b.WriteString(key)
+	}
+
+	return b.String()
+}
+
+// expandFile expands the variables in a YAML file, as described in
+// expandNode. Files with no variables are returned as they are.
+func expandFile(data []byte, locate func(root *yaml.Node) locator) ([]byte, error) {
+	if !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	root, err := parseDocument(data)
+	if err != nil || root == nil {
+		// Let the parser report the error
+		return data, nil //nolint:nilerr // Reported when parsing
+	}
+
+	if _, err := expandNode(root, locate(root)); err != nil {
+		return nil, err
+	}
+
+	expanded, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding expanded file: %w", err)
+	}
+
+	return expanded, nil
+}
+
+// expandNode expands the variables in the keys and values of a YAML node in
+// place, so the expanded values can't change the structure of the file. On
+// error, it returns the node that could not be expanded.
+func expandNode(root *yaml.Node, locate locator) (*yaml.Node, error) {
+	var walk func(node *yaml.Node, path []string) (*yaml.Node, error)
+
+	walk = func(node *yaml.Node, path []string) (*yaml.Node, error) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				if failed, err := walk(child, path); err != nil {
+					return failed, err
+				}
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				if failed, err := walk(child, append(path, "["+strconv.Itoa(i)+"]")); err != nil {
+					return failed, err
+				}
+			}
+		case yaml.MappingNode:
+			for key, value := range pairs(node) {
+				// Errors point at the key as written in the file
+				keyPath := append(path, key.Value)
+
+				if failed, err := walk(key, keyPath); err != nil {
+					return failed, err
+				}
+
+				if failed, err := walk(value, keyPath); err != nil {
+					return failed, err
+				}
+			}
+		case yaml.ScalarNode:
+			value, err := expandString(node.Value)
+			if err != nil {
+				if where := locate(path); where != "" {
+					err = fmt.Errorf("%s: %w", where, err)
+				}
+
+				return node, err
+			}
+
+			node.Value = value
+		case yaml.AliasNode:
+			// Aliases point at nodes expanded where they are anchored
+		}
+
+		return nil, nil
+	}
+
+	return walk(root, nil)
+}
+
+// expandString expands ${VAR} and ${VAR:-default} to the value of the
+// environment variable, using the default if it is unset or empty, and
+// ${file:path} to the contents of the file with trailing newlines removed.
+// $${ is kept as a literal ${.
+func expandString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	b := strings.Builder{}
+
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+
+			return b.String(), nil
+		}
+
+		if start > 0 && s[start-1] == '$' {
+			b.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+
+			continue
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("%w: %q is missing a closing }", ErrInvalidVariable, s[start:])
+		}
+
+		value, err := resolveVariable(s[start+2 : start+end])
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(s[:start] + value)
+		s = s[start+end+1:]
+	}
+}
+
+// resolveVariable returns the value of the expression inside ${...}.
+func resolveVariable(expr string) (string, error) {
+	if path, ok := strings.CutPrefix(expr, "file:"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading secret file: %w", err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, fallback, hasDefault := strings.Cut(expr, ":-")
+	if !variableName.MatchString(name) {
+		return "", fmt.Errorf("%w: ${%s} is not a valid variable name", ErrInvalidVariable, expr)
+	}
+
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+
+	if hasDefault {
+		return fallback, nil
+	}
+
+	return "", fmt.Errorf("%w: %s is not set", ErrMissingVariable, name)
+}
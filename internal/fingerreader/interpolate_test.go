@@ -0,0 +1,146 @@
+package fingerreader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+//nolint:paralleltest // Sets environment variables
+func TestReadFingerFile_Interpolation(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("secret@example.com\n"), 0o600))
+
+	t.Setenv("FINGER_TEST_ISSUER", "https://auth.example.com")
+	t.Setenv("FINGER_TEST_NAME", "Doe, John: CEO # founder")
+	t.Setenv("FINGER_TEST_EMPTY", "")
+
+	tests := []struct {
+		name           string
+		urnsContent    string
+		fingersContent string
+		want           *webfingers.WebFinger
+		wantErr        error
+		wantErrText    string
+	}{
+		{
+			name:           "expands variables",
+			urnsContent:    "name: https://schema/name\nissuer: ${FINGER_TEST_ISSUER_URN:-http://openid.net/specs/connect/1.0/issuer}",
+			fingersContent: "user@example.com:\n  name: ${FINGER_TEST_NAME}\n  issuer: ${FINGER_TEST_ISSUER}/realms/main",
+			want: &webfingers.WebFinger{
+				Subject: "acct:user@example.com",
+				Links: []webfingers.Link{
+					{Rel: "http://openid.net/specs/connect/1.0/issuer", Href: "https://auth.example.com/realms/main"},
+				},
+				Properties: map[string]string{"https://schema/name": "Doe, John: CEO # founder"},
+			},
+		},
+		{
+			name:           "uses defaults for empty variables",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  name: ${FINGER_TEST_EMPTY:-John Doe}",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:user@example.com",
+				Properties: map[string]string{"https://schema/name": "John Doe"},
+			},
+		},
+		{
+			name:           "reads secret files",
+			urnsContent:    "email: https://schema/email",
+			fingersContent: "user@example.com:\n  email: ${file:" + secret + "}",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:user@example.com",
+				Properties: map[string]string{"https://schema/email": "secret@example.com"},
+			},
+		},
+		{
+			name:           "keeps escaped variables",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  name: $${FINGER_TEST_NAME}",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:user@example.com",
+				Properties: map[string]string{"https://schema/name": "${FINGER_TEST_NAME}"},
+			},
+		},
+		{
+			name:           "expands structured files",
+			fingersContent: "version: 2\nresources:\n  user@example.com:\n    links:\n      - rel: self\n        href: ${FINGER_TEST_ISSUER}",
+			want: &webfingers.WebFinger{
+				Subject: "acct:user@example.com",
+				Links:   []webfingers.Link{{Rel: "self", Href: "https://auth.example.com"}},
+			},
+		},
+		{
+			name:           "reports missing variables",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  name: ${FINGER_TEST_UNSET}",
+			wantErr:        fingerreader.ErrMissingVariable,
+			wantErrText:    "resource user@example.com, key name: missing variable: FINGER_TEST_UNSET is not set",
+		},
+		{
+			name:           "reports missing variables in structured files",
+			fingersContent: "version: 2\nresources:\n  user@example.com:\n    links:\n      - rel: self\n        href: ${FINGER_TEST_UNSET}",
+			wantErr:        fingerreader.ErrMissingVariable,
+			wantErrText:    "resource user@example.com, key links[0].href",
+		},
+		{
+			name:           "reports missing variables in the URNs file",
+			urnsContent:    "name: ${FINGER_TEST_UNSET}",
+			fingersContent: "user@example.com:\n  name: John Doe",
+			wantErr:        fingerreader.ErrMissingVariable,
+			wantErrText:    "URNs file: key name",
+		},
+		{
+			name:           "reports missing secret files",
+			fingersContent: "user@example.com:\n  name: ${file:" + secret + ".missing}",
+			wantErr:        os.ErrNotExist,
+			wantErrText:    "resource user@example.com, key name",
+		},
+		{
+			name:           "reports invalid variables",
+			fingersContent: "user@example.com:\n  name: ${FINGER TEST}",
+			wantErr:        fingerreader.ErrInvalidVariable,
+		},
+		{
+			name:           "reports unclosed variables",
+			fingersContent: "user@example.com:\n  name: ${FINGER_TEST_NAME",
+			wantErr:        fingerreader.ErrInvalidVariable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			cfg.Debug = true
+			logs := &strings.Builder{}
+			ctx := log.WithLogger(context.Background(), log.NewLogger(logs, cfg))
+
+			f := fingerreader.NewFingerReader()
+			f.URNSFile = []byte(tc.urnsContent)
+			f.FingersFile = []byte(tc.fingersContent)
+
+			got, err := f.ReadFingerFile(ctx)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				require.ErrorContains(t, err, tc.wantErrText)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got["acct:user@example.com"])
+
+			// Expanded values are not logged
+			require.NotContains(t, logs.String(), "secret@example.com")
+		})
+	}
+}
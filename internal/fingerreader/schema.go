@@ -0,0 +1,192 @@
+package fingerreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// StructuredVersion is the version of the structured fingers file schema.
+const StructuredVersion = 2
+
+var (
+	// ErrUnsupportedVersion is returned when the fingers file declares an unknown schema version.
+	ErrUnsupportedVersion = errors.New("unsupported fingers file version")
+	// ErrFlatOnly is returned when a structured fingers file uses defaults or
+	// templates, which only the flat schema supports.
+	ErrFlatOnly = errors.New("defaults and templates are only supported in the flat schema")
+)
+
+// fileHeader holds the fields used to detect the schema of a fingers file.
+// Flat files never have a version, since their root keys are all resources.
+type fileHeader struct {
+	Version int `yaml:"version"`
+}
+
+// structuredFile is the structured (v2) fingers file schema, where links and
+// properties are declared explicitly.
+type structuredFile struct {
+	Version   int                    `yaml:"version"`
+	Resources webfingers.Descriptors `yaml:"resources"`
+
+	// Defaults and Templates are only decoded to reject them with a clear error.
+	Defaults  yaml.Node `yaml:"defaults"`
+	Templates yaml.Node `yaml:"templates"`
+}
+
+// parseFingers parses a fingers file in either the flat or the structured schema.
+func parseFingers(data []byte) (webfingers.Descriptors, error) {
+	header := fileHeader{}
+
+	// Flat files can't be decoded into the header if they have a resource
+	// named "version", but that is not a valid resource anyway.
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return parseFlatFingers(data)
+	}
+
+	switch header.Version {
+	case 0:
+		return parseFlatFingers(data)
+	case StructuredVersion:
+		return parseStructuredFingers(data)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+}
+
+// parseStructuredFingers parses a fingers file in the structured schema. It
+// has no defaults or templates, and resources named like them are rejected
+// so they aren't served by mistake.
+func parseStructuredFingers(data []byte) (webfingers.Descriptors, error) {
+	file := structuredFile{}
+
+	// Reject unknown fields so typos don't go unnoticed
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFingersFile, err)
+	}
+
+	if !file.Defaults.IsZero() || !file.Templates.IsZero() {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFingersFile, ErrFlatOnly)
+	}
+
+	for subject, descriptor := range file.Resources {
+		if webfingers.IsTemplate(subject) {
+			return nil, fmt.Errorf("%w: %w: %s", ErrInvalidFingersFile, ErrFlatOnly, subject)
+		}
+
+		// Resources with no fields are decoded as nil
+		if descriptor == nil {
+			file.Resources[subject] = &webfingers.Descriptor{}
+		}
+	}
+
+	return file.Resources, nil
+}
+
+// fieldValue is the value of a resource field in the fingers file. Besides
+// plain strings, it also accepts lists of strings (e.g. for aliases), which
+// are joined by spaces, and mappings, which are decoded as links.
+type fieldValue struct {
+	value string
+	link  *webfingers.Link
+}
+
+func (v *fieldValue) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind { //nolint:exhaustive // Every other kind is decoded as a string
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return fmt.Errorf("error decoding list: %w", err)
+		}
+
+		v.value = strings.Join(values, " ")
+	case yaml.MappingNode:
+		v.link = &webfingers.Link{}
+		if err := node.Decode(v.link); err != nil {
+			return fmt.Errorf("error decoding link: %w", err)
+		}
+	default:
+		if err := node.Decode(&v.value); err != nil {
+			return fmt.Errorf("error decoding value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// parseFlatFingers parses a fingers file in the flat key/value schema.
+func parseFlatFingers(data []byte) (webfingers.Descriptors, error) {
+	rawResources := make(map[string]map[string]fieldValue)
+
+	if err := yaml.Unmarshal(data, &rawResources); err != nil {
+		return nil, fmt.Errorf("error unmarshalling fingers file: %w", err)
+	}
+
+	rawResources, err := webfingers.Inherit(rawResources, fieldText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFingersFile, err)
+	}
+
+	descriptors := make(webfingers.Descriptors, len(rawResources))
+	for subject, fields := range rawResources {
+		descriptor, err := flatDescriptor(subject, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		descriptors[subject] = descriptor
+	}
+
+	return descriptors, nil
+}
+
+// fieldText returns the text of a field, which is empty for links.
+func fieldText(value fieldValue) string {
+	return value.value
+}
+
+// flatDescriptor converts the fields of a resource in the flat schema into a descriptor.
+func flatDescriptor(subject string, fields map[string]fieldValue) (*webfingers.Descriptor, error) {
+	// Plain values are handled like the simplified webfinger map,
+	// while links are added to the descriptor as they are.
+	values := make(map[string]string, len(fields))
+	for field, value := range fields {
+		if value.link == nil {
+			values[field] = value.value
+		}
+	}
+
+	descriptor := webfingers.Resources{subject: values}.Descriptors()[subject]
+
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		value := fields[field]
+		if value.link == nil {
+			continue
+		}
+
+		if field == webfingers.AliasesField {
+			return nil, fmt.Errorf("%w: aliases of %s must be a list", ErrInvalidFingersFile, subject)
+		}
+
+		// The rel defaults to the field name
+		link := *value.link
+		if link.Rel == "" {
+			link.Rel = field
+		}
+
+		descriptor.Links = append(descriptor.Links, link)
+	}
+
+	return descriptor, nil
+}
@@ -0,0 +1,173 @@
+package fingerreader_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestReadFingerFile_Structured(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		urnsContent    string
+		fingersContent string
+		returns        webfingers.WebFingers
+		wantErr        error
+	}{
+		{
+			name:        "reads structured files",
+			urnsContent: "name: https://schema/name\nwebsite: https://schema/website\navatar: https://schema/avatar",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+    links:
+      - rel: avatar
+        href: https://example.com/avatar-small.png
+        type: image/png
+      - rel: avatar
+        href: https://example.com/avatar-large.png
+        type: image/png
+    properties:
+      name: John Doe
+      website: https://example.com
+`,
+			returns: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					Links: []webfingers.Link{
+						{
+							Rel:  "https://schema/avatar",
+							Type: "image/png",
+							Href: "https://example.com/avatar-small.png",
+						},
+						{
+							Rel:  "https://schema/avatar",
+							Type: "image/png",
+							Href: "https://example.com/avatar-large.png",
+						},
+					},
+					Properties: map[string]string{
+						"https://schema/name":    "John Doe",
+						"https://schema/website": "https://example.com",
+					},
+				},
+			},
+		},
+		{
+			name: "reads resources without fields",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+`,
+			returns: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+				},
+			},
+		},
+		{
+			name: "reads aliases",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+    aliases:
+      - https://example.com/@user
+`,
+			returns: func() webfingers.WebFingers {
+				finger := &webfingers.WebFinger{
+					Subject: "acct:user@example.com",
+					Aliases: []string{"https://example.com/@user"},
+				}
+
+				return webfingers.WebFingers{
+					"acct:user@example.com":     finger,
+					"https://example.com/@user": finger,
+				}
+			}(),
+		},
+		{
+			name: "reads max ages",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+    max_age: 30m
+`,
+			returns: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					MaxAge:  30 * time.Minute,
+				},
+			},
+		},
+		{
+			name: "errors on unknown fields",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+    link:
+      - rel: avatar
+        href: https://example.com/avatar.png
+`,
+			wantErr: fingerreader.ErrInvalidFingersFile,
+		},
+		{
+			name:           "rejects defaults",
+			fingersContent: "version: 2\ndefaults:\n  properties:\n    name: Nobody\nresources:\n  user@example.com: {}\n",
+			wantErr:        fingerreader.ErrFlatOnly,
+		},
+		{
+			name:           "rejects resources named like defaults",
+			fingersContent: "version: 2\nresources:\n  defaults: {}\n  user@example.com: {}\n",
+			wantErr:        fingerreader.ErrFlatOnly,
+		},
+		{
+			name:           "rejects resources named like templates",
+			fingersContent: "version: 2\nresources:\n  .staff: {}\n  user@example.com: {}\n",
+			wantErr:        fingerreader.ErrFlatOnly,
+		},
+		{
+			name:           "errors on unsupported versions",
+			fingersContent: "version: 3\nresources: {}\n",
+			wantErr:        fingerreader.ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			cfg := config.NewConfig()
+			l := log.NewLogger(&strings.Builder{}, cfg)
+
+			ctx = log.WithLogger(ctx, l)
+
+			f := fingerreader.NewFingerReader()
+
+			f.FingersFile = []byte(tc.fingersContent)
+			f.URNSFile = []byte(tc.urnsContent)
+
+			got, err := f.ReadFingerFile(ctx)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.returns, got)
+		})
+	}
+}
@@ -0,0 +1,585 @@
+package fingerreader
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// Severity is how serious a problem is.
+type Severity string
+
+const (
+	// SeverityError is for problems that stop the files from loading.
+	SeverityError Severity = "error"
+	// SeverityWarning is for content that loads but is probably a mistake.
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is an error or a warning found in a file.
+type Problem struct {
+	File     string
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", p.File, p.Severity, p.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Severity, p.Message)
+}
+
+// Problems is a list of problems.
+type Problems []Problem
+
+// Count returns the number of problems with the given severity.
+func (p Problems) Count(severity Severity) int {
+	count := 0
+
+	for _, problem := range p {
+		if problem.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
+
+// registeredRel matches registered link relation types, like "self", which
+// are valid rels without being URIs (RFC 8288).
+var registeredRel = regexp.MustCompile(`^[a-z][a-z0-9.\-]*$`) //nolint:gochecknoglobals // Read-only
+
+// location is where a resource was declared.
+type location struct {
+	subject string
+	file    string
+	line    int
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%s at %s:%d", l.subject, l.file, l.line)
+}
+
+// resourceLines holds the lines where the parts of a resource were declared.
+type resourceLines struct {
+	// links holds the line of each link of the descriptor.
+	links []int
+}
+
+// validator collects the problems found in the files.
+type validator struct {
+	cfg        *config.Config
+	urnAliases webfingers.URNAliases
+	problems   Problems
+
+	// keys holds the normalized subjects and aliases seen so far.
+	keys map[string]location
+	// foldedKeys holds the keys seen so far with their case folded.
+	foldedKeys map[string]location
+}
+
+// Validate checks the URNs and fingers files in cfg and returns every problem
+// found in them, from errors that stop them from loading to suspicious content.
+func Validate(cfg *config.Config) Problems {
+	v := &validator{
+		cfg:        cfg,
+		urnAliases: make(webfingers.URNAliases),
+		keys:       make(map[string]location),
+		foldedKeys: make(map[string]location),
+	}
+
+	if data, err := readFile(cfg.URNPath, config.DefaultURNPath); err != nil {
+		v.add(cfg.URNPath, 0, SeverityError, "error opening URNs file: %v", err)
+	} else {
+		v.validateURNs(cfg.URNPath, data)
+	}
+
+	paths, err := FingerFiles(cfg)
+	if err != nil {
+		v.add(cfg.FingerPath, 0, SeverityError, "error opening fingers file: %v", err)
+	}
+
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err != nil {
+			v.add(path, 0, SeverityError, "error opening fingers file: %v", err)
+		} else {
+			v.validateFingers(path, data)
+		}
+	}
+
+	slices.SortStableFunc(v.problems, func(a, b Problem) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+
+	return v.problems
+}
+
+// add adds a problem found at a line, or in the whole file if line is zero.
+func (v *validator) add(file string, line int, severity Severity, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		File:     file,
+		Line:     line,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// validateURNs checks the URNs file and saves its aliases.
+func (v *validator) validateURNs(file string, data []byte) {
+	root, err := parseDocument(data)
+	if err != nil {
+		v.add(file, 0, SeverityError, "%v", err)
+
+		return
+	}
+
+	if root == nil {
+		return
+	}
+
+	if node, err := expandNode(root, urnLocation); err != nil {
+		v.add(file, node.Line, SeverityError, "%v", err)
+
+		return
+	}
+
+	if root.Kind != yaml.MappingNode {
+		v.add(file, root.Line, SeverityError, "URNs file must be a map of names to URIs")
+
+		return
+	}
+
+	for name, value := range pairs(root) {
+		if value.Kind != yaml.ScalarNode || !isURI(value.Value) {
+			v.add(file, value.Line, SeverityError, "URN of %s is not a URI", name.Value)
+
+			continue
+		}
+
+		v.urnAliases[name.Value] = value.Value
+	}
+}
+
+// validateFingers checks the fingers file.
+func (v *validator) validateFingers(file string, data []byte) {
+	root, err := parseDocument(data)
+	if err != nil {
+		v.add(file, 0, SeverityError, "%v", err)
+
+		return
+	}
+
+	if root == nil {
+		return
+	}
+
+	if node, err := expandNode(root, fingersLocation(root)); err != nil {
+		v.add(file, node.Line, SeverityError, "%v", err)
+
+		return
+	}
+
+	if root.Kind != yaml.MappingNode {
+		v.add(file, root.Line, SeverityError, "fingers file must be a map of resources")
+
+		return
+	}
+
+	// Detect the schema the same way the files are loaded
+	header := fileHeader{}
+	if err := root.Decode(&header); err != nil || header.Version == 0 {
+		// Files that can't be decoded have their errors reported per field
+		rawResources := make(map[string]map[string]fieldValue)
+		if err := root.Decode(&rawResources); err == nil {
+			if _, err := webfingers.Inherit(rawResources, fieldText); err != nil {
+				v.add(file, 0, SeverityError, "%v", err)
+			}
+		}
+
+		for key, value := range pairs(root) {
+			v.validateFlatResource(file, key, value)
+		}
+
+		return
+	}
+
+	if header.Version != StructuredVersion {
+		v.add(file, root.Line, SeverityError, "%v: %d", ErrUnsupportedVersion, header.Version)
+
+		return
+	}
+
+	for key, value := range pairs(root) {
+		switch key.Value {
+		case "version":
+		case "resources":
+			if isNull(value) {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode {
+				v.add(file, value.Line, SeverityError, "resources must be a map")
+
+				continue
+			}
+
+			for subject, resource := range pairs(value) {
+				v.validateStructuredResource(file, subject, resource)
+			}
+		case webfingers.DefaultsResource, "templates":
+			v.add(file, key.Line, SeverityError, "%v", ErrFlatOnly)
+		default:
+			v.add(file, key.Line, SeverityError, "unknown field %q", key.Value)
+		}
+	}
+}
+
+// validateFlatResource checks a resource in the flat schema.
+func (v *validator) validateFlatResource(file string, key, value *yaml.Node) {
+	descriptor := &webfingers.Descriptor{}
+	lines := resourceLines{}
+
+	if !isNull(value) && value.Kind != yaml.MappingNode {
+		v.add(file, value.Line, SeverityError, "%s must be a map of fields", key.Value)
+
+		return
+	}
+
+	for field, node := range pairs(value) {
+		decoded := fieldValue{}
+		if err := node.Decode(&decoded); err != nil {
+			v.add(file, node.Line, SeverityError, "%v", err)
+
+			continue
+		}
+
+		// Convert each field on its own to know where its links come from
+		fieldDescriptor, err := flatDescriptor(key.Value, map[string]fieldValue{field.Value: decoded})
+		if err != nil {
+			v.add(file, node.Line, SeverityError, "%v", err)
+
+			continue
+		}
+
+		// Inheritance is checked for the whole file
+		if field.Value == webfingers.ExtendsField || field.Value == webfingers.UnsetField {
+			continue
+		}
+
+		if field.Value == webfingers.AliasesField {
+			descriptor.Aliases = append(descriptor.Aliases, fieldDescriptor.Aliases...)
+
+			continue
+		}
+
+		if field.Value == webfingers.MaxAgeField {
+			v.checkMaxAge(file, node.Line, fieldDescriptor.MaxAge)
+
+			continue
+		}
+
+		// Field names are meant to be URN aliases, unless they are a full URI
+		if decoded.link == nil || decoded.link.Rel == "" {
+			v.checkName(file, field.Line, field.Value, false)
+		} else {
+			v.checkName(file, node.Line, decoded.link.Rel, true)
+		}
+
+		for _, link := range fieldDescriptor.Links {
+			descriptor.Links = append(descriptor.Links, link)
+			lines.links = append(lines.links, node.Line)
+		}
+
+		for name, property := range fieldDescriptor.Properties {
+			if looksLikeLink(property) {
+				v.add(file, node.Line, SeverityWarning, "%s looks like a link but is not a valid URI, so it is a property", name)
+			}
+
+			if descriptor.Properties == nil {
+				descriptor.Properties = make(map[string]string)
+			}
+
+			descriptor.Properties[name] = property
+		}
+	}
+
+	// Templates are not served, so they only need valid fields
+	if webfingers.IsTemplate(key.Value) {
+		v.checkLinks(file, descriptor, lines)
+
+		return
+	}
+
+	v.validateResource(file, key, descriptor, lines)
+}
+
+// linkFields are the fields a link can have.
+var linkFields = []string{"rel", "type", "href", "template", "titles", "properties"} //nolint:gochecknoglobals // Read-only
+
+// validateStructuredResource checks a resource in the structured schema.
+func (v *validator) validateStructuredResource(file string, key, value *yaml.Node) {
+	descriptor := &webfingers.Descriptor{}
+	lines := resourceLines{}
+
+	if webfingers.IsTemplate(key.Value) {
+		v.add(file, key.Line, SeverityError, "%s: %v", key.Value, ErrFlatOnly)
+
+		return
+	}
+
+	if !isNull(value) && value.Kind != yaml.MappingNode {
+		v.add(file, value.Line, SeverityError, "%s must be a map", key.Value)
+
+		return
+	}
+
+	for field, node := range pairs(value) {
+		switch field.Value {
+		case "aliases":
+			if err := node.Decode(&descriptor.Aliases); err != nil {
+				v.add(file, node.Line, SeverityError, "aliases of %s must be a list", key.Value)
+			}
+		case "links":
+			if node.Kind != yaml.SequenceNode {
+				v.add(file, node.Line, SeverityError, "links of %s must be a list", key.Value)
+
+				continue
+			}
+
+			for _, linkNode := range node.Content {
+				link, ok := v.decodeLink(file, linkNode)
+				if !ok {
+					continue
+				}
+
+				descriptor.Links = append(descriptor.Links, link)
+				lines.links = append(lines.links, linkNode.Line)
+			}
+		case "max_age":
+			if err := node.Decode(&descriptor.MaxAge); err != nil {
+				v.add(file, node.Line, SeverityError, "max_age of %s must be a duration", key.Value)
+
+				continue
+			}
+
+			v.checkMaxAge(file, node.Line, descriptor.MaxAge)
+		case "properties":
+			if err := node.Decode(&descriptor.Properties); err != nil {
+				v.add(file, node.Line, SeverityError, "properties of %s must be a map of strings", key.Value)
+
+				continue
+			}
+
+			for name := range pairs(node) {
+				v.checkName(file, name.Line, name.Value, false)
+			}
+		default:
+			v.add(file, field.Line, SeverityError, "unknown field %q in %s", field.Value, key.Value)
+		}
+	}
+
+	v.validateResource(file, key, descriptor, lines)
+}
+
+// decodeLink decodes a link of the structured schema.
+func (v *validator) decodeLink(file string, node *yaml.Node) (webfingers.Link, bool) {
+	link := webfingers.Link{}
+
+	if node.Kind != yaml.MappingNode {
+		v.add(file, node.Line, SeverityError, "links must be maps")
+
+		return link, false
+	}
+
+	ok := true
+
+	for field := range pairs(node) {
+		if !slices.Contains(linkFields, field.Value) {
+			v.add(file, field.Line, SeverityError, "unknown link field %q", field.Value)
+
+			ok = false
+		}
+	}
+
+	if err := node.Decode(&link); err != nil {
+		v.add(file, node.Line, SeverityError, "%v", err)
+
+		return link, false
+	}
+
+	if link.Rel != "" {
+		v.checkName(file, node.Line, link.Rel, true)
+	}
+
+	for name := range link.Properties {
+		v.checkName(file, node.Line, name, false)
+	}
+
+	return link, ok
+}
+
+// checkMaxAge checks that a max age is a positive duration.
+func (v *validator) checkMaxAge(file string, line int, maxAge string) {
+	if d, err := time.ParseDuration(maxAge); err != nil || d <= 0 {
+		v.add(file, line, SeverityError, "%v: %q is not a positive duration like 1h", webfingers.ErrInvalidMaxAge, maxAge)
+	}
+}
+
+// checkName warns about names that are neither URN aliases nor URIs. If rel
+// is true, registered link relation types are allowed too.
+func (v *validator) checkName(file string, line int, name string, rel bool) {
+	if _, ok := v.urnAliases[name]; ok || isURI(name) || (rel && registeredRel.MatchString(name)) {
+		return
+	}
+
+	v.add(file, line, SeverityWarning, "%s is not an alias in the URNs file nor a URI, so it is used as is", name)
+}
+
+// validateResource checks a resource the same way it is checked when loaded,
+// and then checks it against the resources before it.
+func (v *validator) validateResource(file string, key *yaml.Node, descriptor *webfingers.Descriptor, lines resourceLines) {
+	subject := key.Value
+
+	// Check the subject and aliases first, so link errors point at the links
+	onlyAliases := webfingers.Descriptors{subject: {Aliases: descriptor.Aliases}}
+
+	fingers, err := webfingers.NewWebFingersFromDescriptors(onlyAliases, nil)
+	if err != nil {
+		v.add(file, key.Line, SeverityError, "%v", err)
+
+		return
+	}
+
+	v.checkCollisions(file, key, fingers)
+	v.checkLinks(file, descriptor, lines)
+}
+
+// checkLinks checks the links of a resource.
+func (v *validator) checkLinks(file string, descriptor *webfingers.Descriptor, lines resourceLines) {
+	for i, link := range descriptor.Links {
+		if err := link.Validate(); err != nil {
+			v.add(file, lines.links[i], SeverityError, "%v", err)
+
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(link.Href), "http://") {
+			v.add(file, lines.links[i], SeverityWarning, "%s links to an insecure http:// URL", link.Rel)
+		}
+	}
+}
+
+// checkCollisions checks that the subject and aliases of a resource are not
+// used by another one once normalized, and warns about the ones that only
+// differ in case.
+func (v *validator) checkCollisions(file string, key *yaml.Node, fingers webfingers.WebFingers) {
+	normalized, err := fingers.Normalized(v.cfg.Normalizer())
+	if err != nil {
+		v.add(file, key.Line, SeverityError, "%v", err)
+
+		return
+	}
+
+	here := location{subject: key.Value, file: file, line: key.Line}
+	folder := webfingers.Normalizer{IgnoreLocalCase: true}
+
+	for _, resource := range slices.Sorted(maps.Keys(normalized)) {
+		if other, ok := v.keys[resource]; ok {
+			v.add(file, key.Line, SeverityError, "%s is already used by %s", resource, other)
+
+			continue
+		}
+
+		v.keys[resource] = here
+
+		// Patterns and the like can't always be folded, and they don't need to
+		folded, err := folder.Normalize(resource)
+		if err != nil {
+			continue
+		}
+
+		// Resources can't collide with themselves
+		if other, ok := v.foldedKeys[folded]; ok && other != here && !v.cfg.IgnoreCase {
+			v.add(
+				file, key.Line, SeverityWarning,
+				"%s only differs in case from %s, and they collide with --ignore-case", resource, other,
+			)
+
+			continue
+		}
+
+		v.foldedKeys[folded] = here
+	}
+}
+
+// looksLikeLink reports whether a value that is not a URI was probably meant
+// as a link, like "example.com/me" or "https://example .com".
+func looksLikeLink(value string) bool {
+	switch {
+	case strings.Contains(value, "://"):
+		return true
+	case strings.ContainsAny(value, " \t\n"):
+		return false
+	default:
+		return strings.HasPrefix(value, "www.") ||
+			strings.HasPrefix(value, "//") ||
+			(strings.Contains(value, ".") && strings.Contains(value, "/"))
+	}
+}
+
+// isURI reports whether value is an absolute URI.
+func isURI(value string) bool {
+	_, err := url.ParseRequestURI(value)
+
+	return err == nil
+}
+
+// parseDocument parses a YAML document and returns its root node, or nil if
+// the document is empty.
+func parseDocument(data []byte) (*yaml.Node, error) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing file: %w", err)
+	}
+
+	if len(doc.Content) == 0 || isNull(doc.Content[0]) {
+		return nil, nil //nolint:nilnil // Empty files are valid
+	}
+
+	return doc.Content[0], nil
+}
+
+// isNull reports whether the node is empty.
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// pairs iterates over the keys and values of a mapping node.
+func pairs(node *yaml.Node) iter.Seq2[*yaml.Node, *yaml.Node] {
+	return func(yield func(*yaml.Node, *yaml.Node) bool) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !yield(node.Content[i], node.Content[i+1]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package fingerreader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	type wantProblem struct {
+		line     int
+		severity fingerreader.Severity
+		contains string
+	}
+
+	tests := []struct {
+		name           string
+		urnsContent    string
+		fingersContent string
+		ignoreCase     bool
+		want           []wantProblem
+	}{
+		{
+			name:        "accepts valid files",
+			urnsContent: "name: https://schema/name\nprofile: https://schema/profile",
+			fingersContent: `user@example.com:
+  aliases:
+    - https://example.com/@user
+  name: John Doe
+  profile: https://example.com/user
+`,
+		},
+		{
+			name:           "reports syntax errors",
+			fingersContent: "user@example.com:\n  name: [",
+			want: []wantProblem{
+				{0, fingerreader.SeverityError, "error parsing file"},
+			},
+		},
+		{
+			name:           "reports invalid URNs",
+			urnsContent:    "name: https://schema/name\nprofile: not a uri",
+			fingersContent: "",
+			want: []wantProblem{
+				{2, fingerreader.SeverityError, "URN of profile is not a URI"},
+			},
+		},
+		{
+			name:        "reports every error with its line",
+			urnsContent: "name: https://schema/name\nprofile: https://schema/profile",
+			fingersContent: `user@example.com:
+  name: John Doe
+  profile:
+    href: not a uri
+invalid@:
+  name: Nobody
+other@example.com:
+  aliases:
+    key: value
+`,
+			want: []wantProblem{
+				{4, fingerreader.SeverityError, "href of profile is not a URI"},
+				{5, fingerreader.SeverityError, "invalid resource"},
+				{9, fingerreader.SeverityError, "aliases of other@example.com must be a list"},
+			},
+		},
+		{
+			name:        "warns about suspicious content",
+			urnsContent: "name: https://schema/name\nprofile: https://schema/profile\nwebsite: https://schema/website",
+			fingersContent: `user@example.com:
+  nmae: John Doe
+  website: example.com/john
+  profile: http://example.com/user
+`,
+			want: []wantProblem{
+				{2, fingerreader.SeverityWarning, "nmae is not an alias"},
+				{3, fingerreader.SeverityWarning, "website looks like a link"},
+				{4, fingerreader.SeverityWarning, "insecure http:// URL"},
+			},
+		},
+		{
+			name: "reports resources colliding after normalization",
+			fingersContent: `user@example.com:
+  aliases:
+    - https://example.com/@user
+acct:user@EXAMPLE.com:
+https://example.com/@user:
+`,
+			want: []wantProblem{
+				{4, fingerreader.SeverityError, "acct:user@example.com is already used by user@example.com"},
+				{5, fingerreader.SeverityError, "https://example.com/@user is already used by user@example.com"},
+			},
+		},
+		{
+			name:           "warns about resources that only differ in case",
+			fingersContent: "user@example.com:\nUser@example.com:\n",
+			want: []wantProblem{
+				{2, fingerreader.SeverityWarning, "only differs in case"},
+			},
+		},
+		{
+			name:           "accepts aliases of the resource itself",
+			fingersContent: "user@example.com:\n  aliases: acct:user@example.com acct:User@example.com\n",
+		},
+		{
+			name:           "reports resources that differ in case when ignoring it",
+			fingersContent: "user@example.com:\nUser@example.com:\n",
+			ignoreCase:     true,
+			want: []wantProblem{
+				{2, fingerreader.SeverityError, "is already used by"},
+			},
+		},
+		{
+			name:        "checks structured files",
+			urnsContent: "name: https://schema/name",
+			fingersContent: `version: 2
+resources:
+  user@example.com:
+    links:
+      - rel: self
+        href: https://example.com/user
+      - rel: avatar
+        hreff: https://example.com/avatar.png
+      - href: https://example.com
+    properties:
+      name: John Doe
+      nick: johnny
+    alias:
+      - https://example.com/@user
+`,
+			want: []wantProblem{
+				{8, fingerreader.SeverityError, `unknown link field "hreff"`},
+				{9, fingerreader.SeverityError, "missing rel"},
+				{12, fingerreader.SeverityWarning, "nick is not an alias"},
+				{13, fingerreader.SeverityError, `unknown field "alias"`},
+			},
+		},
+		{
+			name:           "checks max ages",
+			fingersContent: "user@example.com:\n  max_age: 1h\nother@example.com:\n  max_age: forever\n",
+			want: []wantProblem{
+				{4, fingerreader.SeverityError, "invalid max age"},
+			},
+		},
+		{
+			name:           "checks max ages in structured files",
+			fingersContent: "version: 2\nresources:\n  user@example.com:\n    max_age: 1h\n  other@example.com:\n    max_age: 0s\n",
+			want: []wantProblem{
+				{6, fingerreader.SeverityError, "invalid max age"},
+			},
+		},
+		{
+			name:        "checks templates",
+			urnsContent: "name: https://schema/name\nprofile: https://schema/profile",
+			fingersContent: `defaults:
+  name: Nobody
+.base:
+  extends: .missing
+  profile:
+    href: not a uri
+user@example.com:
+  extends: .base
+  unset: name
+`,
+			want: []wantProblem{
+				{0, fingerreader.SeverityError, "unknown template: .base extends .missing"},
+				{6, fingerreader.SeverityError, "href of profile is not a URI"},
+			},
+		},
+		{
+			name:           "rejects templates in structured files",
+			fingersContent: "version: 2\ndefaults: {}\nresources:\n  .staff: {}\n  user@example.com: {}\n",
+			want: []wantProblem{
+				{2, fingerreader.SeverityError, "defaults and templates are only supported in the flat schema"},
+				{4, fingerreader.SeverityError, ".staff: defaults and templates are only supported in the flat schema"},
+			},
+		},
+		{
+			name:           "reports missing variables",
+			urnsContent:    "name: https://schema/name",
+			fingersContent: "user@example.com:\n  name: John Doe\n  email: ${FINGER_TEST_UNSET_EMAIL}\n",
+			want: []wantProblem{
+				{3, fingerreader.SeverityError, "resource user@example.com, key email: missing variable"},
+			},
+		},
+		{
+			name:           "reports unsupported versions",
+			fingersContent: "version: 3\nresources:\n",
+			want: []wantProblem{
+				{1, fingerreader.SeverityError, "unsupported fingers file version"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.NewConfig()
+			cfg.IgnoreCase = tc.ignoreCase
+
+			urnsFileName, urnsCleanup := newTempFile(t, tc.urnsContent)
+			defer urnsCleanup()
+
+			fingersFileName, fingersCleanup := newTempFile(t, tc.fingersContent)
+			defer fingersCleanup()
+
+			cfg.URNPath = urnsFileName
+			cfg.FingerPath = fingersFileName
+
+			problems := fingerreader.Validate(cfg)
+			require.Len(t, problems, len(tc.want), "problems: %v", problems)
+
+			for i, want := range tc.want {
+				require.Equal(t, want.line, problems[i].Line, problems[i].String())
+				require.Equal(t, want.severity, problems[i].Severity, problems[i].String())
+				require.Contains(t, problems[i].Message, want.contains)
+			}
+		})
+	}
+
+	t.Run("reports missing files", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := config.NewConfig()
+		cfg.URNPath = "invalid"
+		cfg.FingerPath = "invalid"
+
+		problems := fingerreader.Validate(cfg)
+		require.Len(t, problems, 2)
+		require.Equal(t, 2, problems.Count(fingerreader.SeverityError))
+	})
+
+	t.Run("checks every fingers file", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		engineering := filepath.Join(dir, "engineering.yml")
+		sales := filepath.Join(dir, "sales.yml")
+
+		require.NoError(t, os.WriteFile(engineering, []byte("alice@example.com:\n  name: Alice\n"), 0o600))
+		require.NoError(t, os.WriteFile(sales, []byte("bob@example.com:\n  name: Bob\nalice@example.com:\n  name: Alice\n"), 0o600))
+
+		cfg := config.NewConfig()
+		cfg.URNPath = filepath.Join(t.TempDir(), "urns.yml")
+		cfg.FingerPath = dir
+
+		require.NoError(t, os.WriteFile(cfg.URNPath, []byte("name: https://schema/name"), 0o600))
+
+		problems := fingerreader.Validate(cfg)
+		require.Len(t, problems, 1)
+		require.Equal(t, sales, problems[0].File)
+		require.Equal(t, 3, problems[0].Line)
+		require.Contains(t, problems[0].Message, "already used by alice@example.com at "+engineering+":1")
+	})
+}
+
+func TestProblem_String(t *testing.T) {
+	t.Parallel()
+
+	problem := fingerreader.Problem{
+		File:     "fingers.yml",
+		Line:     3,
+		Severity: fingerreader.SeverityWarning,
+		Message:  "something is off",
+	}
+
+	require.Equal(t, "fingers.yml:3: warning: something is off", problem.String())
+
+	problem.Line = 0
+	require.Equal(t, "fingers.yml: warning: something is off", problem.String())
+}
@@ -0,0 +1,132 @@
+package fingerreader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// ErrInvalidVHostsFile is returned when the vhosts file has an invalid structure.
+var ErrInvalidVHostsFile = errors.New("invalid vhosts file")
+
+// vhostEntry is a host in the vhosts file. Its fingers and URNs are either
+// read from files or written inline, in the same schemas as the files.
+type vhostEntry struct {
+	FingerFile string    `yaml:"finger-file"`
+	Fingers    yaml.Node `yaml:"fingers"`
+	URNFile    string    `yaml:"urn-file"`
+	URNs       yaml.Node `yaml:"urns"`
+	Domains    []string  `yaml:"domains"`
+}
+
+// ReadVHosts reads the vhosts file in cfg and the files of each of its hosts.
+// Besides the virtual hosts, it returns the paths of every file read.
+//
+// Relative paths are resolved from the directory of the vhosts file. Hosts
+// without URNs use the ones in the URNs file of cfg.
+func ReadVHosts(ctx context.Context, cfg *config.Config) (webfingers.VirtualHosts, []string, error) {
+	data, err := os.ReadFile(cfg.VHostsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening vhosts file: %w", err)
+	}
+
+	entries := make(map[string]*vhostEntry)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidVHostsFile, err)
+	}
+
+	if cfg.VHostsDefault != "" {
+		if _, ok := entries[cfg.VHostsDefault]; !ok {
+			return nil, nil, fmt.Errorf("%w: default host %s is not in the file", ErrInvalidVHostsFile, cfg.VHostsDefault)
+		}
+	}
+
+	files := []string{cfg.VHostsPath}
+	hosts := make(webfingers.VirtualHosts, len(entries))
+
+	// Go over the hosts in order so errors are always the same
+	for _, host := range slices.Sorted(maps.Keys(entries)) {
+		if strings.ContainsAny(host, "/@") {
+			return nil, nil, fmt.Errorf("%w: %s is not a host name", ErrInvalidVHostsFile, host)
+		}
+
+		entry := entries[host]
+		if entry == nil || (entry.FingerFile == "" && entry.Fingers.IsZero()) {
+			return nil, nil, fmt.Errorf("%w: host %s has no fingers", ErrInvalidVHostsFile, host)
+		}
+
+		f := NewFingerReader()
+
+		if f.FingersFile, err = readHostFile(cfg.VHostsPath, entry.FingerFile, &entry.Fingers, &files); err != nil {
+			return nil, nil, fmt.Errorf("error reading fingers of %s: %w", host, err)
+		}
+
+		// Hosts share the global URNs unless they have their own
+		if entry.URNFile == "" && entry.URNs.IsZero() {
+			files = append(files, cfg.URNPath)
+
+			if f.URNSFile, err = readFile(cfg.URNPath, config.DefaultURNPath); err != nil {
+				return nil, nil, fmt.Errorf("error opening URNs file: %w", err)
+			}
+		} else if f.URNSFile, err = readHostFile(cfg.VHostsPath, entry.URNFile, &entry.URNs, &files); err != nil {
+			return nil, nil, fmt.Errorf("error reading URNs of %s: %w", host, err)
+		}
+
+		fingers, err := f.ReadFingerFile(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing fingers of %s: %w", host, err)
+		}
+
+		hosts[host] = &webfingers.VirtualHost{
+			Fingers: fingers,
+			Domains: entry.Domains,
+		}
+	}
+
+	// Hosts may share files
+	slices.Sort(files)
+
+	return hosts, slices.Compact(files), nil
+}
+
+// readHostFile returns the inline values of a host if set, or the contents of
+// the file at path otherwise, adding it to files. Relative paths are resolved
+// from the directory of the vhosts file.
+func readHostFile(vhostsPath, path string, inline *yaml.Node, files *[]string) ([]byte, error) {
+	if !inline.IsZero() {
+		if path != "" {
+			return nil, fmt.Errorf("%w: a file and inline values can't be used together", ErrInvalidVHostsFile)
+		}
+
+		data, err := yaml.Marshal(inline)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding inline values: %w", err)
+		}
+
+		return data, nil
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(vhostsPath), path)
+	}
+
+	*files = append(*files, path)
+
+	return readFile(path, "")
+}
@@ -0,0 +1,149 @@
+package fingerreader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+	"git.maronato.dev/maronato/finger/internal/log"
+)
+
+func TestReadVHosts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		defaultHost string
+		wantErr     error
+		check       func(t *testing.T, dir string, hosts map[string]map[string]string, files []string)
+	}{
+		{
+			name: "reads files and inline fingers",
+			files: map[string]string{
+				"urns.yml": "name: https://schema/name",
+				"vhosts.yml": `example.com:
+  finger-file: example.com/fingers.yml
+  domains: [example.com, example.org]
+other.com:
+  fingers:
+    bob@other.com:
+      nick: Bob
+  urns:
+    nick: https://schema/nick
+`,
+				"example.com/fingers.yml": "alice@example.com:\n  name: Alice",
+			},
+			check: func(t *testing.T, dir string, hosts map[string]map[string]string, files []string) {
+				t.Helper()
+
+				require.Equal(t, map[string]map[string]string{
+					"example.com": {"acct:alice@example.com": "https://schema/name=Alice"},
+					"other.com":   {"acct:bob@other.com": "https://schema/nick=Bob"},
+				}, hosts)
+
+				require.Equal(t, []string{
+					filepath.Join(dir, "example.com/fingers.yml"),
+					filepath.Join(dir, "urns.yml"),
+					filepath.Join(dir, "vhosts.yml"),
+				}, files)
+			},
+		},
+		{
+			name: "errors on hosts without fingers",
+			files: map[string]string{
+				"vhosts.yml": "example.com:\n  urn-file: urns.yml\n",
+			},
+			wantErr: fingerreader.ErrInvalidVHostsFile,
+		},
+		{
+			name: "errors on files and inline fingers together",
+			files: map[string]string{
+				"vhosts.yml": "example.com:\n  finger-file: fingers.yml\n  fingers:\n    alice@example.com: {}\n",
+			},
+			wantErr: fingerreader.ErrInvalidVHostsFile,
+		},
+		{
+			name: "errors on unknown fields",
+			files: map[string]string{
+				"vhosts.yml": "example.com:\n  finger_file: fingers.yml\n",
+			},
+			wantErr: fingerreader.ErrInvalidVHostsFile,
+		},
+		{
+			name: "errors on invalid hosts",
+			files: map[string]string{
+				"vhosts.yml": "https://example.com:\n  fingers:\n    alice@example.com: {}\n",
+			},
+			wantErr: fingerreader.ErrInvalidVHostsFile,
+		},
+		{
+			name: "errors on unknown default hosts",
+			files: map[string]string{
+				"vhosts.yml": "example.com:\n  fingers:\n    alice@example.com: {}\n",
+			},
+			defaultHost: "other.com",
+			wantErr:     fingerreader.ErrInvalidVHostsFile,
+		},
+		{
+			name: "errors on missing files",
+			files: map[string]string{
+				"vhosts.yml": "example.com:\n  finger-file: missing.yml\n",
+			},
+			wantErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			}
+
+			cfg := config.NewConfig()
+			cfg.URNPath = filepath.Join(dir, "urns.yml")
+			cfg.VHostsPath = filepath.Join(dir, "vhosts.yml")
+			cfg.VHostsDefault = tc.defaultHost
+
+			ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, cfg))
+
+			hosts, files, err := fingerreader.ReadVHosts(ctx, cfg)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			// Summarize each webfinger by its only property
+			got := make(map[string]map[string]string)
+
+			for host, vhost := range hosts {
+				got[host] = make(map[string]string)
+
+				for resource, finger := range vhost.Fingers {
+					for name, value := range finger.Properties {
+						got[host][resource] = name + "=" + value
+					}
+				}
+			}
+
+			tc.check(t, dir, got, files)
+		})
+	}
+}
@@ -37,6 +37,16 @@ func FromContext(ctx context.Context) *slog.Logger {
 	return l
 }
 
+// FromContextOrDefault returns the logger in the context, or slog.Default if
+// there is none, for code that also runs outside of the server.
+func FromContextOrDefault(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
 func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
 	return context.WithValue(ctx, loggerCtxKey{}, l)
 }
@@ -2,6 +2,7 @@ package log_test
 
 import (
 	"context"
+	"log/slog"
 	"strings"
 	"testing"
 
@@ -87,3 +88,13 @@ func TestFromContext(t *testing.T) {
 		require.NotNil(t, l2)
 	})
 }
+
+func TestFromContextOrDefault(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := log.NewLogger(nil, config.NewConfig())
+
+	require.Equal(t, slog.Default(), log.FromContextOrDefault(ctx))
+	require.Equal(t, l, log.FromContextOrDefault(log.WithLogger(ctx, l)))
+}
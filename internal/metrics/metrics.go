@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.maronato.dev/maronato/finger/handler"
+)
+
+const (
+	// Namespace is the prefix of every metric name.
+	Namespace = "finger"
+	// OtherRoute is the route label of requests to unknown paths, which are
+	// grouped together to keep the number of series bounded.
+	OtherRoute = "other"
+	// ContentType is the content type of the Prometheus text format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the request duration histogram.
+var DurationBuckets = []float64{ //nolint:gochecknoglobals // Read-only
+	0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+}
+
+type metricsCtxKey struct{}
+
+// requestKey labels the request counter.
+type requestKey struct {
+	route  string
+	status int
+}
+
+// histogram is a cumulative histogram with DurationBuckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics collects the server metrics. The methods of a nil *Metrics do
+// nothing, so code can record metrics without checking if they are enabled.
+type Metrics struct {
+	routes []string
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+
+	lookupHits      atomic.Uint64
+	lookupMisses    atomic.Uint64
+	resources       atomic.Int64
+	reloadSuccesses atomic.Uint64
+	reloadFailures  atomic.Uint64
+
+	rateLimitedHits      atomic.Uint64
+	rateLimitedMisses    atomic.Uint64
+	rateLimitedExhausted atomic.Uint64
+	rateLimitClients     atomic.Int64
+	rateLimitEvictions   atomic.Uint64
+}
+
+// New creates new metrics. Requests are labeled by their path if it is one of
+// routes, and with OtherRoute otherwise.
+func New(routes ...string) *Metrics {
+	return &Metrics{
+		routes:    routes,
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// FromContext returns the metrics in the context, or nil if there are none.
+func FromContext(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(metricsCtxKey{}).(*Metrics)
+
+	return m
+}
+
+// WithMetrics returns a copy of the context holding the metrics.
+func WithMetrics(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, metricsCtxKey{}, m)
+}
+
+// ObserveRequest records a request to path. Requests to the webfinger
+// endpoint also count as lookup hits or misses.
+func (m *Metrics) ObserveRequest(path string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	route := OtherRoute
+	if slices.Contains(m.routes, path) {
+		route = path
+	}
+
+	if route == handler.WebfingerPath {
+		switch status {
+		// Not modified responses found the resource too
+		case http.StatusOK, http.StatusNotModified:
+			m.lookupHits.Add(1)
+		case http.StatusNotFound:
+			m.lookupMisses.Add(1)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{route, status}]++
+
+	h, ok := m.durations[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		m.durations[route] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += seconds
+}
+
+// SetResources sets the number of loaded resources.
+func (m *Metrics) SetResources(n int) {
+	if m == nil {
+		return
+	}
+
+	m.resources.Store(int64(n))
+}
+
+// ObserveReload records a reload of the webfinger files, which failed if err is not nil.
+func (m *Metrics) ObserveReload(err error) {
+	if m == nil {
+		return
+	}
+
+	if err != nil {
+		m.reloadFailures.Add(1)
+	} else {
+		m.reloadSuccesses.Add(1)
+	}
+}
+
+// ObserveRateLimited records a request rejected by the rate limiter because
+// the budget ("hit", "miss" or "all") was spent.
+func (m *Metrics) ObserveRateLimited(budget string) {
+	if m == nil {
+		return
+	}
+
+	switch budget {
+	case "hit":
+		m.rateLimitedHits.Add(1)
+	case "miss":
+		m.rateLimitedMisses.Add(1)
+	default:
+		m.rateLimitedExhausted.Add(1)
+	}
+}
+
+// SetRateLimitClients sets the number of clients tracked by the rate limiter.
+func (m *Metrics) SetRateLimitClients(n int) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimitClients.Store(int64(n))
+}
+
+// ObserveRateLimitEviction records a client forgotten by the rate limiter to
+// make room for another.
+func (m *Metrics) ObserveRateLimitEviction() {
+	if m == nil {
+		return
+	}
+
+	m.rateLimitEvictions.Add(1)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+
+	mw := &metricWriter{w: w}
+
+	mw.header("http_requests_total", "counter", "Number of HTTP requests by route and status.")
+
+	m.mu.Lock()
+
+	keys := slices.SortedFunc(maps.Keys(m.requests), func(a, b requestKey) int {
+		return cmp.Or(cmp.Compare(a.route, b.route), cmp.Compare(a.status, b.status))
+	})
+	for _, key := range keys {
+		mw.sample("http_requests_total", fmt.Sprintf(`route=%q,status="%d"`, key.route, key.status), m.requests[key])
+	}
+
+	mw.header("http_request_duration_seconds", "histogram", "Duration of HTTP requests by route.")
+
+	for _, route := range slices.Sorted(maps.Keys(m.durations)) {
+		h := m.durations[route]
+
+		for i, bound := range DurationBuckets {
+			labels := fmt.Sprintf(`route=%q,le="%s"`, route, strconv.FormatFloat(bound, 'g', -1, 64))
+			mw.sample("http_request_duration_seconds_bucket", labels, h.counts[i])
+		}
+
+		mw.sample("http_request_duration_seconds_bucket", fmt.Sprintf(`route=%q,le="+Inf"`, route), h.count)
+		mw.sample("http_request_duration_seconds_sum", fmt.Sprintf(`route=%q`, route), h.sum)
+		mw.sample("http_request_duration_seconds_count", fmt.Sprintf(`route=%q`, route), h.count)
+	}
+
+	m.mu.Unlock()
+
+	mw.header("lookups_total", "counter", "Number of webfinger lookups by result.")
+	mw.sample("lookups_total", `result="hit"`, m.lookupHits.Load())
+	mw.sample("lookups_total", `result="miss"`, m.lookupMisses.Load())
+
+	mw.header("resources", "gauge", "Number of loaded resources.")
+	mw.sample("resources", "", m.resources.Load())
+
+	mw.header("reloads_total", "counter", "Number of reloads of the webfinger files by result.")
+	mw.sample("reloads_total", `result="success"`, m.reloadSuccesses.Load())
+	mw.sample("reloads_total", `result="failure"`, m.reloadFailures.Load())
+
+	mw.header("rate_limited_total", "counter", "Number of requests rejected by the rate limiter by budget.")
+	mw.sample("rate_limited_total", `budget="hit"`, m.rateLimitedHits.Load())
+	mw.sample("rate_limited_total", `budget="miss"`, m.rateLimitedMisses.Load())
+	mw.sample("rate_limited_total", `budget="all"`, m.rateLimitedExhausted.Load())
+
+	mw.header("rate_limit_clients", "gauge", "Number of clients tracked by the rate limiter.")
+	mw.sample("rate_limit_clients", "", m.rateLimitClients.Load())
+
+	mw.header("rate_limit_evictions_total", "counter", "Number of clients forgotten by the rate limiter to make room for others.")
+	mw.sample("rate_limit_evictions_total", "", m.rateLimitEvictions.Load())
+
+	return mw.n, mw.err
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+
+		_, _ = m.WriteTo(w)
+	})
+}
+
+// metricWriter writes metrics, keeping the first error.
+type metricWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (mw *metricWriter) printf(format string, args ...any) {
+	if mw.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(mw.w, format, args...)
+	mw.n += int64(n)
+	mw.err = err
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func (mw *metricWriter) header(name, kind, help string) {
+	mw.printf("# HELP %s_%s %s\n", Namespace, name, help)
+	mw.printf("# TYPE %s_%s %s\n", Namespace, name, kind)
+}
+
+// sample writes a sample of a metric.
+func (mw *metricWriter) sample(name, labels string, value any) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+
+	mw.printf("%s_%s%s %v\n", Namespace, name, labels, value)
+}
@@ -0,0 +1,116 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New(handler.WebfingerPath, "/healthz")
+
+	m.ObserveRequest(handler.WebfingerPath, http.StatusOK, time.Millisecond)
+	m.ObserveRequest(handler.WebfingerPath, http.StatusNotFound, 2*time.Second)
+	m.ObserveRequest(handler.WebfingerPath, http.StatusNotModified, time.Millisecond)
+	m.ObserveRequest("/healthz", http.StatusOK, 0)
+	m.ObserveRequest("/random", http.StatusNotFound, 0)
+	m.SetResources(42)
+	m.ObserveReload(nil)
+	m.ObserveReload(errors.New("invalid file"))
+
+	out := &strings.Builder{}
+	_, err := m.WriteTo(out)
+	require.NoError(t, err)
+
+	for _, want := range []string{
+		"# TYPE finger_http_requests_total counter",
+		`finger_http_requests_total{route="/.well-known/webfinger",status="200"} 1`,
+		`finger_http_requests_total{route="/.well-known/webfinger",status="404"} 1`,
+		`finger_http_requests_total{route="/.well-known/webfinger",status="304"} 1`,
+		`finger_http_requests_total{route="/healthz",status="200"} 1`,
+		// Unknown paths are grouped together
+		`finger_http_requests_total{route="other",status="404"} 1`,
+		"# TYPE finger_http_request_duration_seconds histogram",
+		`finger_http_request_duration_seconds_bucket{route="/.well-known/webfinger",le="0.0005"} 0`,
+		`finger_http_request_duration_seconds_bucket{route="/.well-known/webfinger",le="0.001"} 2`,
+		`finger_http_request_duration_seconds_bucket{route="/.well-known/webfinger",le="1"} 2`,
+		`finger_http_request_duration_seconds_bucket{route="/.well-known/webfinger",le="+Inf"} 3`,
+		`finger_http_request_duration_seconds_sum{route="/.well-known/webfinger"} 2.002`,
+		`finger_http_request_duration_seconds_count{route="/.well-known/webfinger"} 3`,
+		`finger_lookups_total{result="hit"} 2`,
+		`finger_lookups_total{result="miss"} 1`,
+		"finger_resources 42",
+		`finger_reloads_total{result="success"} 1`,
+		`finger_reloads_total{result="failure"} 1`,
+	} {
+		require.Contains(t, out.String(), want+"\n")
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	t.Parallel()
+
+	// Metrics are disabled when none are in the context
+	m := metrics.FromContext(context.Background())
+	require.Nil(t, m)
+
+	// A nil *Metrics does nothing
+	m.ObserveRequest("/", http.StatusOK, time.Second)
+	m.SetResources(1)
+	m.ObserveReload(nil)
+
+	n, err := m.WriteTo(&strings.Builder{})
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New()
+	ctx := metrics.WithMetrics(context.Background(), m)
+
+	require.Same(t, m, metrics.FromContext(ctx))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New()
+	m.SetResources(3)
+
+	t.Run("serves metrics", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", http.NoBody)
+
+		m.Handler().ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+		require.Contains(t, w.Body.String(), "finger_resources 3\n")
+	})
+
+	t.Run("only allows GET", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/metrics", http.NoBody)
+
+		m.Handler().ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
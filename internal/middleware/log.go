@@ -1,11 +1,13 @@
 package middleware
 
 import (
+	"cmp"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
 )
 
 func RequestLogger(next http.Handler) http.Handler {
@@ -22,6 +24,10 @@ func RequestLogger(next http.Handler) http.Handler {
 		next.ServeHTTP(wrapped, r)
 
 		status := wrapped.Status()
+		duration := time.Since(start)
+
+		// Handlers that write nothing send a 200
+		metrics.FromContext(ctx).ObserveRequest(r.URL.Path, cmp.Or(status, http.StatusOK), duration)
 
 		// Log the request
 		lg := l.With(
@@ -29,7 +35,7 @@ func RequestLogger(next http.Handler) http.Handler {
 			slog.String("path", r.URL.Path),
 			slog.Int("status", status),
 			slog.String("remote", r.RemoteAddr),
-			slog.Duration("duration", time.Since(start)),
+			slog.Duration("duration", duration),
 		)
 
 		switch {
@@ -11,6 +11,7 @@ import (
 
 	"git.maronato.dev/maronato/finger/internal/config"
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
 	"git.maronato.dev/maronato/finger/internal/middleware"
 )
 
@@ -37,3 +38,27 @@ func TestRequestLogger(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 	require.NotEmpty(t, stdout.String())
 }
+
+func TestRequestLogger_Metrics(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+
+	l := log.NewLogger(&strings.Builder{}, cfg)
+	ctx = log.WithLogger(ctx, l)
+
+	m := metrics.New("/")
+	ctx = metrics.WithMetrics(ctx, m)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/", http.NoBody)
+
+	// Handlers that write nothing are counted as a 200
+	middleware.RequestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(w, r)
+
+	out := &strings.Builder{}
+	_, err := m.WriteTo(out)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), `finger_http_requests_total{route="/",status="200"} 1`)
+}
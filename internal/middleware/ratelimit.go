@@ -0,0 +1,420 @@
+package middleware
+
+import (
+	"container/list"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+)
+
+const (
+	// BudgetHit is the budget of requests that found a resource.
+	BudgetHit = "hit"
+	// BudgetMiss is the budget of requests that didn't, which is what
+	// enumerating accounts mostly costs.
+	BudgetMiss = "miss"
+	// BudgetAll is reported when a client spent both budgets.
+	BudgetAll = "all"
+
+	// DefaultMaxClients is the default number of clients tracked by RateLimiter.
+	DefaultMaxClients = 10000
+
+	// ipv6PrefixLen is the length of the prefix that identifies an IPv6
+	// client, since a single host usually has a whole /64.
+	ipv6PrefixLen = 64
+)
+
+// RateLimitConfig configures RateLimiter.
+type RateLimitConfig struct {
+	// HitsPerMinute and MissesPerMinute are the number of hits and misses a
+	// client can make per minute, all at once or spread out. Zero disables
+	// the budget.
+	HitsPerMinute   int
+	MissesPerMinute int
+	// Allow are the networks that are never limited.
+	Allow []netip.Prefix
+	// TrustedProxies are the networks of reverse proxies whose
+	// X-Forwarded-For header is trusted to hold the address of the client.
+	// Requests from other addresses are limited by their own address.
+	TrustedProxies []netip.Prefix
+	// MaxClients is the number of clients tracked at once. When it is
+	// reached, the least recently seen client is forgotten. Defaults to
+	// DefaultMaxClients.
+	MaxClients int
+}
+
+// bucket is a token bucket that fills up at a steady rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last refill, up to capacity.
+func (b *bucket) refill(now time.Time, capacity int) {
+	perSecond := float64(capacity) / time.Minute.Seconds()
+
+	b.tokens = math.Min(float64(capacity), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+}
+
+// wait returns how long until the bucket has a token.
+func (b *bucket) wait(capacity int) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	perSecond := float64(capacity) / time.Minute.Seconds()
+
+	return time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// client holds the budgets of a client.
+type client struct {
+	key     string
+	budgets map[string]*bucket
+}
+
+// RateLimiter limits how many requests each client can make, with separate
+// budgets for hits and misses. Clients are identified by their IP address,
+// or their /64 network for IPv6.
+type RateLimiter struct {
+	capacity map[string]int
+	allow    []netip.Prefix
+	proxies  []netip.Prefix
+	max      int
+
+	mu      sync.Mutex
+	clients map[string]*list.Element
+	// recent holds the clients from the most to the least recently seen.
+	recent *list.List
+}
+
+// NewRateLimiter creates a new rate limiter.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	maxClients := cfg.MaxClients
+	if maxClients <= 0 {
+		maxClients = DefaultMaxClients
+	}
+
+	return &RateLimiter{
+		capacity: map[string]int{BudgetHit: cfg.HitsPerMinute, BudgetMiss: cfg.MissesPerMinute},
+		allow:    cfg.Allow,
+		proxies:  cfg.TrustedProxies,
+		max:      maxClients,
+		clients:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Handler limits the requests to next. Responses with an error status are
+// misses and every other response is a hit. Once either budget is spent,
+// every request of the client is rejected with a 429 and a Retry-After
+// header before it is handled, so the rejection doesn't tell whether a
+// resource exists. Responses that would spend a budget emptied by concurrent
+// requests are replaced by a 429 too.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := rl.clientKey(rl.clientAddr(r))
+		if !ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		m := metrics.FromContext(r.Context())
+
+		// Reject clients that spent any budget before the lookup, so the
+		// rejection is the same whether the resource exists or not
+		if budget, wait := rl.exhausted(key, m); wait > 0 {
+			rl.reject(w, r, key, budget, wait)
+
+			return
+		}
+
+		lw := &limitedWriter{ResponseWriter: w, rl: rl, r: r, key: key}
+		next.ServeHTTP(lw, r)
+
+		// Handlers that write nothing send a 200
+		if !lw.written {
+			lw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// clientAddr returns the address of the client that made the request. When
+// the request comes from a trusted proxy, it is the last address in
+// X-Forwarded-For that is not a trusted proxy too, since the ones before it
+// can be set by the client.
+func (rl *RateLimiter) clientAddr(r *http.Request) string {
+	if !rl.trusted(r.RemoteAddr) {
+		return r.RemoteAddr
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	addr := r.RemoteAddr
+
+	for _, hop := range slices.Backward(forwarded) {
+		if _, err := netip.ParseAddr(strings.TrimSpace(hop)); err != nil {
+			break
+		}
+
+		addr = strings.TrimSpace(hop)
+		if !rl.trusted(addr) {
+			break
+		}
+	}
+
+	return addr
+}
+
+// trusted reports whether addr is a trusted proxy.
+func (rl *RateLimiter) trusted(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	ip = ip.Unmap()
+
+	return slices.ContainsFunc(rl.proxies, func(prefix netip.Prefix) bool {
+		return prefix.Contains(ip)
+	})
+}
+
+// clientKey returns the key of the client at addr, and false if the client
+// is not limited.
+func (rl *RateLimiter) clientKey(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		// Limit unknown addresses together
+		return host, true
+	}
+
+	ip = ip.Unmap()
+
+	for _, prefix := range rl.allow {
+		if prefix.Contains(ip) {
+			return "", false
+		}
+	}
+
+	if ip.Is6() {
+		prefix, _ := ip.Prefix(ipv6PrefixLen)
+
+		return prefix.String(), true
+	}
+
+	return ip.String(), true
+}
+
+// exhausted returns the budget the client spent, or BudgetAll if it spent
+// both, and how long until it has tokens in all of them again. The wait is
+// zero if it has tokens in every budget now.
+func (rl *RateLimiter) exhausted(key string, m *metrics.Metrics) (string, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	c := rl.client(key, m)
+	now := time.Now()
+
+	var (
+		spent string
+		wait  time.Duration
+	)
+
+	for _, budget := range []string{BudgetHit, BudgetMiss} {
+		capacity := rl.capacity[budget]
+		if capacity <= 0 {
+			continue
+		}
+
+		b := c.budgets[budget]
+		b.refill(now, capacity)
+
+		w := b.wait(capacity)
+		if w == 0 {
+			continue
+		}
+
+		if spent == "" {
+			spent = budget
+		} else {
+			spent = BudgetAll
+		}
+
+		wait = max(wait, w)
+	}
+
+	return spent, wait
+}
+
+// take takes a token from a budget of the client. If there is none, it
+// returns how long until there is one.
+func (rl *RateLimiter) take(key, budget string, m *metrics.Metrics) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	capacity := rl.capacity[budget]
+	if capacity <= 0 {
+		return 0
+	}
+
+	b := rl.client(key, m).budgets[budget]
+	b.refill(time.Now(), capacity)
+
+	if wait := b.wait(capacity); wait > 0 {
+		return wait
+	}
+
+	b.tokens--
+
+	return 0
+}
+
+// client returns the client with the key, creating it with full budgets if
+// needed. It must be called with the lock held.
+func (rl *RateLimiter) client(key string, m *metrics.Metrics) *client {
+	if el, ok := rl.clients[key]; ok {
+		rl.recent.MoveToFront(el)
+
+		return el.Value.(*client) //nolint:forcetypeassert // Only clients are stored
+	}
+
+	// Forget the least recently seen client to make room
+	if rl.recent.Len() >= rl.max {
+		oldest := rl.recent.Back()
+		rl.recent.Remove(oldest)
+		delete(rl.clients, oldest.Value.(*client).key) //nolint:forcetypeassert // Only clients are stored
+
+		m.ObserveRateLimitEviction()
+	}
+
+	now := time.Now()
+	c := &client{key: key, budgets: make(map[string]*bucket, len(rl.capacity))}
+
+	for budget, capacity := range rl.capacity {
+		c.budgets[budget] = &bucket{tokens: float64(capacity), last: now}
+	}
+
+	rl.clients[key] = rl.recent.PushFront(c)
+	m.SetRateLimitClients(rl.recent.Len())
+
+	return c
+}
+
+// Clients returns the number of clients being tracked.
+func (rl *RateLimiter) Clients() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.recent.Len()
+}
+
+// reject responds with a 429 telling the client when to retry.
+func (rl *RateLimiter) reject(w http.ResponseWriter, r *http.Request, key, budget string, wait time.Duration) {
+	ctx := r.Context()
+
+	metrics.FromContext(ctx).ObserveRateLimited(budget)
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+
+	// Clients hitting the limit send many requests, so keep them out of the
+	// default logs
+	log.FromContext(ctx).Debug("Rate limited",
+		slog.String("client", key),
+		slog.String("budget", budget),
+		slog.Int("retry_after", retryAfter),
+	)
+
+	// Drop the headers of the response being replaced
+	for _, header := range []string{"Content-Type", "Content-Length", "Cache-Control", "ETag", "Last-Modified"} {
+		w.Header().Del(header)
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// limitedWriter spends the budget of the response when its status is
+// written, replacing it with a 429 if the budget is spent.
+type limitedWriter struct {
+	http.ResponseWriter
+
+	rl  *RateLimiter
+	r   *http.Request
+	key string
+
+	written bool
+	// rejected is set when the response was replaced, so the rest of it is dropped.
+	rejected bool
+}
+
+func (w *limitedWriter) WriteHeader(code int) {
+	// Informational responses are followed by the real one
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+
+		return
+	}
+
+	if w.written {
+		return
+	}
+
+	w.written = true
+
+	budget := BudgetHit
+	if code >= http.StatusBadRequest {
+		budget = BudgetMiss
+	}
+
+	if wait := w.rl.take(w.key, budget, metrics.FromContext(w.r.Context())); wait > 0 {
+		w.rejected = true
+		w.rl.reject(w.ResponseWriter, w.r, w.key, budget, wait)
+
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.rejected {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck // We don't want to wrap the error
+}
+
+// Unwrap returns the original response writer.
+func (w *limitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
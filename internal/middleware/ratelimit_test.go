@@ -0,0 +1,244 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+	"git.maronato.dev/maronato/finger/internal/middleware"
+)
+
+// lookupHandler finds the resources starting with "hit" and counts its calls.
+func lookupHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+
+		if !strings.HasPrefix(r.URL.Query().Get("resource"), "hit") {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/jrd+json")
+		w.Write([]byte(`{}`)) //nolint:errcheck // Tests
+	})
+}
+
+// limitedRequest makes a request from addr and returns the response.
+func limitedRequest(ctx context.Context, h http.Handler, addr, resource string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/?resource="+resource, http.NoBody)
+	r.RemoteAddr = addr
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// forwardedRequest makes a request from addr on behalf of the addresses in
+// forwarded and returns the response.
+func forwardedRequest(ctx context.Context, h http.Handler, addr, forwarded, resource string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/?resource="+resource, http.NoBody)
+	r.RemoteAddr = addr
+	r.Header.Set("X-Forwarded-For", forwarded)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func newLimiterContext() (context.Context, *metrics.Metrics) {
+	cfg := config.NewConfig()
+	m := metrics.New("/")
+
+	ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, cfg))
+
+	return metrics.WithMetrics(ctx, m), m
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("limits hits and misses separately", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, m := newLimiterContext()
+
+		calls := 0
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			HitsPerMinute:   3,
+			MissesPerMinute: 2,
+		}).Handler(lookupHandler(&calls))
+
+		// Misses don't spend hits
+		require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code)
+
+		for range 3 {
+			require.Equal(t, http.StatusOK, limitedRequest(ctx, h, "192.0.2.1:1234", "hit").Code)
+		}
+
+		// Other clients have their own budgets
+		require.Equal(t, http.StatusOK, limitedRequest(ctx, h, "192.0.2.2:1234", "hit").Code)
+
+		// Once hits are spent, requests are rejected without being handled
+		before := calls
+		w := limitedRequest(ctx, h, "192.0.2.1:1234", "hit")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Equal(t, "20", w.Header().Get("Retry-After"))
+		require.NotEqual(t, "application/jrd+json", w.Header().Get("Content-Type"))
+		require.NotContains(t, w.Body.String(), "{}")
+		require.Equal(t, http.StatusTooManyRequests, limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code)
+		require.Equal(t, before, calls)
+
+		out := &strings.Builder{}
+		_, err := m.WriteTo(out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), `finger_rate_limited_total{budget="hit"} 2`)
+		require.Contains(t, out.String(), `finger_rate_limit_clients 2`)
+	})
+
+	t.Run("doesn't tell hits from misses once misses are spent", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, m := newLimiterContext()
+
+		calls := 0
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			HitsPerMinute:   100,
+			MissesPerMinute: 2,
+		}).Handler(lookupHandler(&calls))
+
+		for range 2 {
+			require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code)
+		}
+
+		before := calls
+		hit := limitedRequest(ctx, h, "192.0.2.1:1234", "hit")
+		miss := limitedRequest(ctx, h, "192.0.2.1:1234", "miss")
+
+		require.Equal(t, http.StatusTooManyRequests, hit.Code)
+		require.Equal(t, miss.Code, hit.Code)
+		require.Equal(t, miss.Header(), hit.Header())
+		require.Equal(t, miss.Body.String(), hit.Body.String())
+		require.Equal(t, before, calls)
+
+		out := &strings.Builder{}
+		_, err := m.WriteTo(out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), `finger_rate_limited_total{budget="miss"} 2`)
+	})
+
+	t.Run("refills budgets over time", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, _ := newLimiterContext()
+
+		calls := 0
+		// A token every 20ms
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			MissesPerMinute: 3000,
+		}).Handler(lookupHandler(&calls))
+
+		// Spend the budget
+		for limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code != http.StatusTooManyRequests {
+			require.LessOrEqual(t, calls, 6000)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code)
+	})
+
+	t.Run("skips allowed networks", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, _ := newLimiterContext()
+
+		calls := 0
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			MissesPerMinute: 1,
+			Allow:           []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		}).Handler(lookupHandler(&calls))
+
+		for range 5 {
+			require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "10.1.2.3:1234", "miss").Code)
+		}
+	})
+
+	t.Run("reads the client from trusted proxies", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, _ := newLimiterContext()
+
+		calls := 0
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			MissesPerMinute: 1,
+			TrustedProxies:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		}).Handler(lookupHandler(&calls))
+
+		// Clients behind the same proxy have their own budgets
+		require.Equal(t, http.StatusNotFound, forwardedRequest(ctx, h, "10.0.0.1:1234", "192.0.2.1", "miss").Code)
+		require.Equal(t, http.StatusNotFound, forwardedRequest(ctx, h, "10.0.0.1:1234", "192.0.2.2", "miss").Code)
+		require.Equal(t, http.StatusTooManyRequests, forwardedRequest(ctx, h, "10.0.0.1:1234", "192.0.2.1", "miss").Code)
+
+		// Addresses set by the client before the proxies are ignored
+		require.Equal(t, http.StatusTooManyRequests,
+			forwardedRequest(ctx, h, "10.0.0.1:1234", "198.51.100.1, 192.0.2.1, 10.0.0.2", "miss").Code)
+
+		// Untrusted clients can't pick their address
+		require.Equal(t, http.StatusNotFound, forwardedRequest(ctx, h, "203.0.113.1:1234", "192.0.2.3", "miss").Code)
+		require.Equal(t, http.StatusTooManyRequests, forwardedRequest(ctx, h, "203.0.113.1:1234", "192.0.2.4", "miss").Code)
+	})
+
+	t.Run("limits IPv6 networks together", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, _ := newLimiterContext()
+
+		calls := 0
+		h := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			MissesPerMinute: 1,
+		}).Handler(lookupHandler(&calls))
+
+		require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "[2001:db8::1]:1234", "miss").Code)
+		require.Equal(t, http.StatusTooManyRequests, limitedRequest(ctx, h, "[2001:db8::2]:1234", "miss").Code)
+		require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "[2001:db8:0:1::1]:1234", "miss").Code)
+	})
+
+	t.Run("tracks a bounded number of clients", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, m := newLimiterContext()
+
+		calls := 0
+		rl := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			MissesPerMinute: 1,
+			MaxClients:      2,
+		})
+		h := rl.Handler(lookupHandler(&calls))
+
+		limitedRequest(ctx, h, "192.0.2.1:1234", "miss")
+		limitedRequest(ctx, h, "192.0.2.2:1234", "miss")
+		limitedRequest(ctx, h, "192.0.2.3:1234", "miss")
+
+		require.Equal(t, 2, rl.Clients())
+
+		// The least recently seen client was forgotten
+		require.Equal(t, http.StatusNotFound, limitedRequest(ctx, h, "192.0.2.1:1234", "miss").Code)
+
+		out := &strings.Builder{}
+		_, err := m.WriteTo(out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), `finger_rate_limit_evictions_total 2`)
+	})
+}
@@ -0,0 +1,255 @@
+package reloader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/filewatch"
+	"git.maronato.dev/maronato/finger/internal/fingerreader"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// Reloader loads the webfinger files into a store and reloads them when they change.
+type Reloader struct {
+	cfg   *config.Config
+	store *webfingers.Store
+	hosts *webfingers.HostStore
+
+	// files are the files read by the last load, and states holds their
+	// state when they were read.
+	files  []string
+	states filewatch.States
+	// listErr is the error of the last failed listing of the files, so a
+	// listing that keeps failing is only reloaded once.
+	listErr string
+
+	// mu serializes loads, which can also be triggered by the admin API.
+	mu sync.Mutex
+}
+
+// New creates a new reloader that loads the files in cfg into the store.
+func New(cfg *config.Config, store *webfingers.Store) *Reloader {
+	return &Reloader{
+		cfg:    cfg,
+		store:  store,
+		files:  []string{cfg.URNPath},
+		states: make(filewatch.States),
+	}
+}
+
+// NewVHosts creates a new reloader that loads the vhosts file in cfg, and the
+// files of each host, into the host store.
+func NewVHosts(cfg *config.Config, hosts *webfingers.HostStore) *Reloader {
+	return &Reloader{
+		cfg:    cfg,
+		hosts:  hosts,
+		files:  []string{cfg.VHostsPath},
+		states: make(filewatch.States),
+	}
+}
+
+// Load reads and parses the webfinger files and replaces the webfingers in the store.
+// If the files can't be read or parsed, the store is left untouched.
+func (r *Reloader) Load(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	m := metrics.FromContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files, err := r.watched()
+	if err != nil {
+		files = r.files
+	}
+
+	states := filewatch.Stat(files...)
+
+	var count int
+
+	if r.hosts != nil {
+		count, files, err = r.loadHosts(ctx)
+	} else {
+		count, files, err = r.loadFingers(ctx)
+	}
+
+	m.ObserveReload(err)
+
+	if err != nil {
+		return err
+	}
+
+	// Files read for the first time are only checked from now on
+	for path, state := range filewatch.Stat(files...) {
+		if _, ok := states[path]; !ok {
+			states[path] = state
+		}
+	}
+
+	r.files = files
+	r.states = states
+
+	m.SetResources(count)
+	l.Info(fmt.Sprintf("Loaded %d webfingers", count))
+
+	return nil
+}
+
+// loadFingers loads the webfinger files into the store and returns the number
+// of webfingers loaded and the files read.
+func (r *Reloader) loadFingers(ctx context.Context) (int, []string, error) {
+	fingers, files, err := r.read(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Keep the modification times of the webfingers that didn't change
+	fingers.SetModTimes(r.store.Load(), time.Now())
+
+	r.store.Store(fingers)
+
+	return fingers.Count(), files, nil
+}
+
+// loadHosts loads the virtual hosts into the host store and returns the number
+// of webfingers loaded and the files read.
+func (r *Reloader) loadHosts(ctx context.Context) (int, []string, error) {
+	hosts, files, err := fingerreader.ReadVHosts(ctx, r.cfg)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading virtual hosts: %w", err)
+	}
+
+	previous := r.hosts.Load()
+	now := time.Now()
+
+	for name, host := range hosts {
+		// Key the webfingers the same way the server normalizes requests
+		host.Fingers, err = host.Fingers.Normalized(r.cfg.Normalizer())
+		if err != nil {
+			return 0, nil, fmt.Errorf("error normalizing webfingers of %s: %w", name, err)
+		}
+
+		if old, ok := previous[name]; ok {
+			host.Fingers.SetModTimes(old.Fingers, now)
+		} else {
+			host.Fingers.SetModTimes(nil, now)
+		}
+	}
+
+	r.hosts.Store(hosts)
+
+	return hosts.Count(), files, nil
+}
+
+// read reads, parses and normalizes the webfinger files, and returns the
+// webfingers and the files read.
+func (r *Reloader) read(ctx context.Context) (webfingers.WebFingers, []string, error) {
+	f := fingerreader.NewFingerReader()
+
+	if err := f.ReadFiles(r.cfg); err != nil {
+		return nil, nil, fmt.Errorf("error reading finger files: %w", err)
+	}
+
+	fingers, err := f.ReadFingerFile(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing finger files: %w", err)
+	}
+
+	// Key the webfingers the same way the server normalizes requests
+	fingers, err = fingers.Normalized(r.cfg.Normalizer())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error normalizing webfingers: %w", err)
+	}
+
+	files := []string{r.cfg.URNPath}
+	for _, file := range f.FingersFiles {
+		files = append(files, file.Path)
+	}
+
+	return fingers, files, nil
+}
+
+// Watch reloads the webfinger files whenever they change on disk or a
+// signal is received on signals. Files are checked for changes every
+// ReloadInterval, and never if it is zero. Errors while reloading are
+// logged and the last webfingers that loaded successfully keep being served.
+// Watch blocks until the context is canceled.
+func (r *Reloader) Watch(ctx context.Context, signals <-chan os.Signal) error {
+	l := log.FromContext(ctx)
+
+	// A nil channel never fires, so polling is disabled without an interval
+	var tick <-chan time.Time
+
+	if r.cfg.ReloadInterval > 0 {
+		ticker := time.NewTicker(r.cfg.ReloadInterval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-signals:
+			l.Info("Reloading webfingers", slog.String("reason", sig.String()))
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+
+			l.Info("Reloading webfingers", slog.String("reason", "files changed"))
+		}
+
+		if err := r.Load(ctx); err != nil {
+			l.Error("Failed to reload webfingers, keeping the previous ones", slog.Any("error", err))
+		}
+	}
+}
+
+// changed reports whether any of the files changed since they were last
+// loaded, or files were added to or removed from the ones to read.
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Files that can't be listed are reported when loading
+	files, err := r.watched()
+	if err != nil {
+		changed := err.Error() != r.listErr
+		r.listErr = err.Error()
+
+		return changed
+	}
+
+	r.listErr = ""
+
+	if !slices.Equal(files, r.files) {
+		return true
+	}
+
+	return r.states.Changed(r.files...)
+}
+
+// watched returns the files to read. The fingers files are listed again, so
+// files added to or removed from their directories and globs are picked up.
+// The files of virtual hosts are the ones read by the last load.
+func (r *Reloader) watched() ([]string, error) {
+	if r.hosts != nil {
+		return r.files, nil
+	}
+
+	paths, err := fingerreader.FingerFiles(r.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error listing fingers files: %w", err)
+	}
+
+	return append([]string{r.cfg.URNPath}, paths...), nil
+}
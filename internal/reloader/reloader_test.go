@@ -0,0 +1,370 @@
+package reloader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
+	"git.maronato.dev/maronato/finger/internal/reloader"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func newConfig(t *testing.T, fingers string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	cfg := config.NewConfig()
+	cfg.URNPath = filepath.Join(dir, "urns.yml")
+	cfg.FingerPath = filepath.Join(dir, "fingers.yml")
+	cfg.ReloadInterval = 10 * time.Millisecond
+
+	require.NoError(t, os.WriteFile(cfg.URNPath, []byte("name: https://schema/name"), 0o600))
+	writeFingers(t, cfg, fingers)
+
+	return cfg
+}
+
+func writeFingers(t *testing.T, cfg *config.Config, content string) {
+	t.Helper()
+
+	// Make sure the modification time changes even on coarse filesystems
+	modTime := time.Now()
+	if info, err := os.Stat(cfg.FingerPath); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+
+	require.NoError(t, os.WriteFile(cfg.FingerPath, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(cfg.FingerPath, modTime, modTime))
+}
+
+func newContext(t *testing.T, cfg *config.Config) context.Context {
+	t.Helper()
+
+	l := log.NewLogger(&strings.Builder{}, cfg)
+
+	return log.WithLogger(t.Context(), l)
+}
+
+func TestReloader_Load(t *testing.T) {
+	t.Parallel()
+
+	t.Run("loads the files into the store", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+		require.Equal(t, "John Doe", store.Load()["acct:user@example.com"].Properties["https://schema/name"])
+	})
+
+	t.Run("normalizes the webfingers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "User@example.com:\n  name: John Doe")
+		cfg.IgnoreCase = true
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+		require.Contains(t, store.Load(), "acct:user@example.com")
+	})
+
+	t.Run("records metrics", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		m := metrics.New()
+		ctx := metrics.WithMetrics(newContext(t, cfg), m)
+
+		r := reloader.New(cfg, webfingers.NewStore(nil))
+
+		require.NoError(t, r.Load(ctx))
+
+		writeFingers(t, cfg, "invalid")
+		require.Error(t, r.Load(ctx))
+
+		out := &strings.Builder{}
+		_, err := m.WriteTo(out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), "finger_resources 1\n")
+		require.Contains(t, out.String(), `finger_reloads_total{result="success"} 1`)
+		require.Contains(t, out.String(), `finger_reloads_total{result="failure"} 1`)
+	})
+
+	t.Run("keeps modification times of unchanged webfingers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe\nother@example.com:\n  name: Jane Doe")
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		user := store.Load()["acct:user@example.com"].ModTime
+		other := store.Load()["acct:other@example.com"].ModTime
+		require.False(t, user.IsZero())
+
+		time.Sleep(time.Millisecond)
+		writeFingers(t, cfg, "user@example.com:\n  name: John Doe\nother@example.com:\n  name: Jane Smith")
+		require.NoError(t, r.Load(ctx))
+
+		require.Equal(t, user, store.Load()["acct:user@example.com"].ModTime)
+		require.True(t, store.Load()["acct:other@example.com"].ModTime.After(other))
+	})
+
+	t.Run("keeps the store on errors", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "invalid")
+		ctx := newContext(t, cfg)
+
+		fingers := webfingers.WebFingers{
+			"acct:user@example.com": {Subject: "acct:user@example.com"},
+		}
+
+		store := webfingers.NewStore(fingers)
+		r := reloader.New(cfg, store)
+
+		require.Error(t, r.Load(ctx))
+		require.Equal(t, fingers, store.Load())
+	})
+}
+
+func TestReloader_Watch(t *testing.T) {
+	t.Parallel()
+
+	waitForName := func(t *testing.T, store *webfingers.Store, want string) {
+		t.Helper()
+
+		require.Eventually(t, func() bool {
+			finger, ok := store.Load()["acct:user@example.com"]
+
+			return ok && finger.Properties["https://schema/name"] == want
+		}, time.Second, 5*time.Millisecond)
+	}
+
+	t.Run("reloads changed files", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		go func() {
+			_ = r.Watch(ctx, nil)
+		}()
+
+		writeFingers(t, cfg, "user@example.com:\n  name: Jane Doe")
+		waitForName(t, store, "Jane Doe")
+	})
+
+	t.Run("keeps the last good webfingers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		go func() {
+			_ = r.Watch(ctx, nil)
+		}()
+
+		writeFingers(t, cfg, "invalid")
+
+		// Wait for a few checks to happen
+		time.Sleep(5 * cfg.ReloadInterval)
+		waitForName(t, store, "John Doe")
+
+		// A later fix is still picked up
+		writeFingers(t, cfg, "user@example.com:\n  name: Jane Doe")
+		waitForName(t, store, "Jane Doe")
+	})
+
+	t.Run("reloads files that can't be listed once", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		m := metrics.New()
+		ctx := metrics.WithMetrics(newContext(t, cfg), m)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		go func() {
+			_ = r.Watch(ctx, nil)
+		}()
+
+		require.NoError(t, os.Remove(cfg.FingerPath))
+
+		// Wait for a few checks to happen
+		time.Sleep(5 * cfg.ReloadInterval)
+
+		out := &strings.Builder{}
+		_, err := m.WriteTo(out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), `finger_reloads_total{result="failure"} 1`)
+
+		// The files are picked up again once they are back
+		writeFingers(t, cfg, "user@example.com:\n  name: Jane Doe")
+		waitForName(t, store, "Jane Doe")
+	})
+
+	t.Run("reloads on signals", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		cfg.ReloadInterval = 0
+		ctx := newContext(t, cfg)
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		signals := make(chan os.Signal, 1)
+
+		go func() {
+			_ = r.Watch(ctx, signals)
+		}()
+
+		writeFingers(t, cfg, "user@example.com:\n  name: Jane Doe")
+
+		// Without polling, nothing changes until the signal
+		time.Sleep(50 * time.Millisecond)
+		waitForName(t, store, "John Doe")
+
+		signals <- syscall.SIGHUP
+
+		waitForName(t, store, "Jane Doe")
+	})
+
+	t.Run("reloads virtual hosts", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		cfg.VHostsPath = filepath.Join(filepath.Dir(cfg.FingerPath), "vhosts.yml")
+		ctx := newContext(t, cfg)
+
+		require.NoError(t, os.WriteFile(cfg.VHostsPath, []byte("example.com:\n  finger-file: fingers.yml\n"), 0o600))
+
+		hosts := webfingers.NewHostStore(nil, "")
+		r := reloader.NewVHosts(cfg, hosts)
+
+		require.NoError(t, r.Load(ctx))
+
+		go func() {
+			_ = r.Watch(ctx, nil)
+		}()
+
+		nameOn := func(host, resource string) string {
+			finger, ok := hosts.Lookup(host, resource)
+			if !ok {
+				return ""
+			}
+
+			return finger.Properties["https://schema/name"]
+		}
+
+		require.Equal(t, "John Doe", nameOn("example.com", "acct:user@example.com"))
+
+		// Files of the hosts are watched too
+		writeFingers(t, cfg, "user@example.com:\n  name: Jane Doe")
+		require.Eventually(t, func() bool {
+			return nameOn("example.com", "acct:user@example.com") == "Jane Doe"
+		}, time.Second, 5*time.Millisecond)
+
+		// And so are new hosts
+		modTime := time.Now().Add(time.Second)
+		require.NoError(t, os.WriteFile(cfg.VHostsPath, []byte(`example.com:
+  finger-file: fingers.yml
+other.com:
+  fingers:
+    user@other.com:
+      name: Other
+`), 0o600))
+		require.NoError(t, os.Chtimes(cfg.VHostsPath, modTime, modTime))
+
+		require.Eventually(t, func() bool {
+			return nameOn("other.com", "acct:user@other.com") == "Other"
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("reloads added and removed files", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "user@example.com:\n  name: John Doe")
+		dir := filepath.Join(filepath.Dir(cfg.FingerPath), "fingers.d")
+		cfg.FingerPath += "," + dir
+		ctx := newContext(t, cfg)
+
+		require.NoError(t, os.Mkdir(dir, 0o700))
+
+		store := webfingers.NewStore(nil)
+		r := reloader.New(cfg, store)
+
+		require.NoError(t, r.Load(ctx))
+
+		go func() {
+			_ = r.Watch(ctx, nil)
+		}()
+
+		added := filepath.Join(dir, "other.yml")
+		require.NoError(t, os.WriteFile(added, []byte("other@example.com:\n  name: Other"), 0o600))
+
+		require.Eventually(t, func() bool {
+			_, ok := store.Load()["acct:other@example.com"]
+
+			return ok
+		}, time.Second, 5*time.Millisecond)
+
+		require.NoError(t, os.Remove(added))
+
+		require.Eventually(t, func() bool {
+			_, ok := store.Load()["acct:other@example.com"]
+
+			return !ok
+		}, time.Second, 5*time.Millisecond)
+		waitForName(t, store, "John Doe")
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newConfig(t, "")
+		ctx, cancel := context.WithCancel(newContext(t, cfg))
+
+		r := reloader.New(cfg, webfingers.NewStore(nil))
+
+		cancel()
+
+		require.NoError(t, r.Watch(ctx, nil))
+	})
+}
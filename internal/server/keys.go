@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"git.maronato.dev/maronato/finger/internal/filewatch"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+// keyReloader holds the signing keys and reloads them when their files
+// change, so keys can be rotated without a restart.
+type keyReloader struct {
+	paths  []string
+	signer *jws.Signer
+
+	// states holds the state of each file when they were last loaded.
+	states filewatch.States
+}
+
+// newKeyReloader loads the keys in the given files. The first key signs.
+func newKeyReloader(paths []string) (*keyReloader, error) {
+	k := &keyReloader{paths: paths}
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// load reads the key files. If they can't be loaded, the current keys are
+// kept.
+func (k *keyReloader) load() error {
+	states := filewatch.Stat(k.paths...)
+
+	keys, err := jws.LoadKeys(k.paths...)
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+
+	if k.signer == nil {
+		k.signer, err = jws.NewSigner(keys...)
+	} else {
+		err = k.signer.SetKeys(keys...)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+
+	k.states = states
+
+	return nil
+}
+
+// signingKey returns the ID of the key that signs.
+func (k *keyReloader) signingKey() string {
+	return k.signer.Keys()[0].ID
+}
+
+// watch reloads the keys whenever their files change, checking them every
+// interval, until the context is done. Keys that fail to load are retried on
+// the next check.
+func (k *keyReloader) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !k.states.Changed(k.paths...) {
+			continue
+		}
+
+		if err := k.load(); err != nil {
+			l.Error("Failed to reload signing keys, keeping the previous ones", slog.Any("error", err))
+
+			continue
+		}
+
+		l.Info("Reloaded signing keys", slog.String("kid", k.signingKey()), slog.Int("keys", len(k.signer.Keys())))
+	}
+}
@@ -0,0 +1,230 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/handler"
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/server"
+	"git.maronato.dev/maronato/finger/jws"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// writeKey writes a new Ed25519 private key to path and returns its ID.
+func writeKey(t *testing.T, path string) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	k, err := jws.NewKey(priv, "")
+	require.NoError(t, err)
+
+	return k.ID
+}
+
+// newSigningConfig returns a config that signs webfingers with a new key.
+func newSigningConfig(t *testing.T, port int) (*config.Config, string) {
+	t.Helper()
+
+	cfg := config.NewConfig()
+	cfg.Port = fmt.Sprint(port)
+	cfg.SigningKeys = filepath.Join(t.TempDir(), "key.pem")
+	cfg.ReloadInterval = 10 * time.Millisecond
+
+	return cfg, writeKey(t, cfg.SigningKeys)
+}
+
+// fetchKeys returns the keys served by the server.
+func fetchKeys(t *testing.T, cfg *config.Config) []*jws.Key {
+	t.Helper()
+
+	resp, err := http.Get("http://" + cfg.GetAddr() + cfg.JWKSPath) //nolint:noctx // Test request
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	keys, err := jws.ParseKeys(data)
+	require.NoError(t, err)
+
+	return keys
+}
+
+func TestStartServer_Signing(t *testing.T) {
+	t.Parallel()
+
+	portGenerator := getPortGenerator()
+
+	// Skip the ports used by the other tests
+	for range 200 {
+		portGenerator()
+	}
+
+	fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+		"user@example.com": {"name": "John Doe"},
+	}, nil)
+	require.NoError(t, err)
+
+	start := func(t *testing.T, cfg *config.Config) {
+		t.Helper()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		ctx = log.WithLogger(ctx, log.NewLogger(&strings.Builder{}, cfg))
+
+		go func() {
+			assert.NoError(t, server.StartServer(ctx, cfg, webfingers.NewStore(fingers)))
+		}()
+
+		// Wait for the server to start
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// signedBy returns the ID of the key that signed a webfinger response.
+	signedBy := func(t *testing.T, cfg *config.Config) (string, error) {
+		t.Helper()
+
+		resp, err := http.Get("http://" + cfg.GetAddr() + "/.well-known/webfinger?resource=acct:user@example.com") //nolint:noctx // Test request
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		k, err := jws.VerifyDetached(resp.Header.Get(handler.SignatureHeader), body, fetchKeys(t, cfg))
+		if err != nil {
+			return "", err //nolint:wrapcheck // Only used in tests
+		}
+
+		return k.ID, nil
+	}
+
+	t.Run("signs webfingers and serves the keys", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, kid := newSigningConfig(t, portGenerator())
+		start(t, cfg)
+
+		got, err := signedBy(t, cfg)
+		require.NoError(t, err)
+		require.Equal(t, kid, got)
+
+		keys := fetchKeys(t, cfg)
+		require.Len(t, keys, 1)
+		require.False(t, keys[0].Private())
+	})
+
+	t.Run("serves the keys at the configured path", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, _ := newSigningConfig(t, portGenerator())
+		cfg.JWKSPath = "/keys"
+		start(t, cfg)
+
+		require.Len(t, fetchKeys(t, cfg), 1)
+	})
+
+	t.Run("reloads rotated keys", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, oldKid := newSigningConfig(t, portGenerator())
+
+		// The previous key is published so its signatures can still be verified
+		current := cfg.SigningKeys
+		previous := filepath.Join(filepath.Dir(current), "previous.pem")
+		writeKey(t, previous)
+
+		cfg.SigningKeys = current + "," + previous
+		start(t, cfg)
+
+		require.Len(t, fetchKeys(t, cfg), 2)
+
+		// Rotate the current key into the previous one
+		data, err := os.ReadFile(current)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(previous, data, 0o600))
+
+		newKid := writeKey(t, current)
+
+		require.Eventually(t, func() bool {
+			kid, err := signedBy(t, cfg)
+
+			return err == nil && kid == newKid
+		}, time.Second, 10*time.Millisecond)
+
+		keys := fetchKeys(t, cfg)
+		require.Len(t, keys, 2)
+		require.Equal(t, oldKid, keys[1].ID)
+	})
+
+	t.Run("keeps the keys if the new ones are invalid", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, kid := newSigningConfig(t, portGenerator())
+		start(t, cfg)
+
+		require.NoError(t, os.WriteFile(cfg.SigningKeys, []byte("invalid"), 0o600))
+		time.Sleep(50 * time.Millisecond)
+
+		got, err := signedBy(t, cfg)
+		require.NoError(t, err)
+		require.Equal(t, kid, got)
+	})
+
+	t.Run("fails with invalid keys", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, _ := newSigningConfig(t, portGenerator())
+		ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, cfg))
+
+		require.NoError(t, os.WriteFile(cfg.SigningKeys, []byte("invalid"), 0o600))
+		require.ErrorIs(t, server.StartServer(ctx, cfg, nil), jws.ErrInvalidKey)
+
+		// Only public keys can't sign
+		public, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		k, err := jws.NewKey(public, "")
+		require.NoError(t, err)
+
+		data, err := json.Marshal(k.JWK())
+		require.NoError(t, err)
+
+		pub := filepath.Join(t.TempDir(), "public.json")
+		require.NoError(t, os.WriteFile(pub, data, 0o600))
+
+		cfg.SigningKeys = pub
+		require.ErrorIs(t, server.StartServer(ctx, cfg, nil), jws.ErrNoSigningKey)
+
+		cfg, _ = newSigningConfig(t, portGenerator())
+		cfg.JWKSPath = server.HealthPath
+		require.ErrorIs(t, server.StartServer(ctx, cfg, nil), config.ErrInvalidConfig)
+	})
+}
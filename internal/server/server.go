@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -13,6 +14,7 @@ import (
 	"git.maronato.dev/maronato/finger/handler"
 	"git.maronato.dev/maronato/finger/internal/config"
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
 	"git.maronato.dev/maronato/finger/internal/middleware"
 	"git.maronato.dev/maronato/finger/webfingers"
 )
@@ -35,38 +37,240 @@ const (
 	RequestTimeout = 7 * 24 * time.Hour
 )
 
-func StartServer(ctx context.Context, cfg *config.Config, fingers webfingers.WebFingers) error {
-	l := log.FromContext(ctx)
+const (
+	// HealthPath is the path of the healthcheck endpoint.
+	HealthPath = "/healthz"
+	// MetricsPath is the path of the metrics endpoint.
+	MetricsPath = "/metrics"
+)
+
+// Routes are the paths served by the server.
+var Routes = []string{ //nolint:gochecknoglobals // Read-only
+	handler.WebfingerPath,
+	handler.HostMetaPath,
+	handler.HostMetaJSONPath,
+	HealthPath,
+	MetricsPath,
+}
+
+// handlerOptions returns the webfinger handler options set in the config.
+func handlerOptions(cfg *config.Config) []handler.Option {
+	opts := []handler.Option{
+		handler.WithNormalizer(cfg.Normalizer()),
+	}
+
+	if origins := config.SplitList(cfg.CORSOrigins); len(origins) > 0 {
+		opts = append(opts, handler.WithCORS(handler.CORSConfig{
+			AllowedOrigins: origins,
+			ExposedHeaders: config.SplitList(cfg.CORSExposeHeaders),
+		}))
+	}
+
+	if cfg.CacheMaxAge > 0 {
+		opts = append(opts, handler.WithCacheMaxAge(cfg.CacheMaxAge))
+	}
+
+	return opts
+}
+
+// Option configures optional parts of the server.
+type Option func(*options)
+
+type options struct {
+	admin http.Handler
+}
+
+// WithAdmin serves the admin API handler on cfg.AdminAddr.
+func WithAdmin(h http.Handler) Option {
+	return func(o *options) {
+		o.admin = h
+	}
+}
+
+// StartServer runs the server until the context is done, serving the
+// webfingers returned by the resolver.
+func StartServer(ctx context.Context, cfg *config.Config, resolver webfingers.Resolver, opts ...Option) error {
+	// Every caller gets the same checks as the serve command
+	if err := cfg.Validate(); err != nil {
+		return err //nolint:wrapcheck // The error is already descriptive
+	}
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// Serve an empty set of webfingers if none is given
+	if resolver == nil {
+		resolver = webfingers.NewStore(nil)
+	}
+
+	m := metrics.FromContext(ctx)
+
+	webfingerOpts := handlerOptions(cfg)
+
+	var keys *keyReloader
+
+	if cfg.Signed() {
+		if slices.Contains(Routes, cfg.JWKSPath) {
+			return fmt.Errorf("%w: jwks path %s is already served", config.ErrInvalidConfig, cfg.JWKSPath)
+		}
+
+		var err error
+
+		keys, err = newKeyReloader(config.SplitList(cfg.SigningKeys))
+		if err != nil {
+			return err
+		}
+
+		log.FromContext(ctx).Info("Signing webfingers",
+			slog.String("kid", keys.signingKey()),
+			slog.Int("keys", len(keys.signer.Keys())),
+		)
+
+		webfingerOpts = append(webfingerOpts, handler.WithSigner(keys.signer))
+	}
+
+	webfinger := handler.ResolverHandler(resolver, webfingerOpts...)
+
+	if cfg.RateLimited() {
+		allow, err := config.ParsePrefixes(cfg.RateLimitAllow)
+		if err != nil {
+			return fmt.Errorf("error parsing rate limit allowlist: %w", err)
+		}
+
+		proxies, err := config.ParsePrefixes(cfg.RateLimitProxies)
+		if err != nil {
+			return fmt.Errorf("error parsing rate limit proxies: %w", err)
+		}
+
+		webfinger = middleware.NewRateLimiter(middleware.RateLimitConfig{
+			HitsPerMinute:   cfg.RateLimitHits,
+			MissesPerMinute: cfg.RateLimitMisses,
+			Allow:           allow,
+			TrustedProxies:  proxies,
+			MaxClients:      cfg.RateLimitClients,
+		}).Handler(webfinger)
+	}
 
 	// Create the server mux
 	mux := http.NewServeMux()
-	mux.Handle("/.well-known/webfinger", handler.WebfingerHandler(fingers))
-	mux.Handle("/healthz", HealthCheckHandler(cfg))
-
-	// Create a new server
-	srv := &http.Server{
-		Addr: cfg.GetAddr(),
-		Handler: middleware.RequestLogger(
-			middleware.Recoverer(
-				http.TimeoutHandler(mux, RequestTimeout, "request timed out"),
-			),
+	mux.Handle(handler.WebfingerPath, webfinger)
+	mux.Handle(handler.HostMetaPath, handler.HostMetaHandler(cfg.PublicURL, handlerOptions(cfg)...))
+	mux.Handle(handler.HostMetaJSONPath, handler.HostMetaJSONHandler(cfg.PublicURL, handlerOptions(cfg)...))
+	mux.Handle(HealthPath, HealthCheckHandler(cfg))
+
+	if keys != nil {
+		mux.Handle(cfg.JWKSPath, handler.KeySetHandler(keys.signer, handlerOptions(cfg)...))
+	}
+
+	// Serve metrics on the main listener unless they have their own
+	if m != nil && cfg.MetricsAddr == "" {
+		mux.Handle(MetricsPath, m.Handler())
+	}
+
+	mainServer := newServer(cfg.GetAddr(), middleware.RequestLogger(
+		middleware.Recoverer(
+			http.TimeoutHandler(mux, RequestTimeout, "request timed out"),
 		),
+	))
+	servers := []*http.Server{mainServer}
+
+	var certs *certReloader
+
+	if cfg.TLSEnabled() {
+		var err error
+
+		certs, err = newCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+
+		mainServer.TLSConfig = tlsConfig(cfg, certs)
+
+		if cfg.RedirectAddr != "" {
+			servers = append(servers, newServer(cfg.RedirectAddr, RedirectHandler(cfg.Port)))
+		}
+	}
+
+	if m != nil && cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle(MetricsPath, m.Handler())
+
+		servers = append(servers, newServer(cfg.MetricsAddr, metricsMux))
+	}
+
+	if o.admin != nil && cfg.AdminAddr != "" {
+		adminServer := newServer(cfg.AdminAddr, middleware.RequestLogger(middleware.Recoverer(o.admin)))
+		// Tokens are sent over HTTPS too when it is enabled
+		adminServer.TLSConfig = mainServer.TLSConfig
+
+		servers = append(servers, adminServer)
+	}
+
+	// Create the errorgroup that will manage the server execution
+	eg, egCtx := errgroup.WithContext(ctx)
+
+	for _, srv := range servers {
+		runServer(egCtx, eg, srv)
+	}
+
+	// Pick up renewed certificates
+	if certs != nil {
+		eg.Go(func() error {
+			certs.watch(egCtx, cfg.ReloadInterval)
+
+			return nil
+		})
+	}
+
+	// Pick up rotated keys
+	if keys != nil {
+		eg.Go(func() error {
+			keys.watch(egCtx, cfg.ReloadInterval)
+
+			return nil
+		})
+	}
+
+	// Wait for the server to exit and check for errors that
+	// are not caused by the context being canceled.
+	if err := eg.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("server exited with error: %w", err)
+	}
+
+	return nil
+}
+
+// newServer creates a new server listening on addr.
+func newServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
 		ReadHeaderTimeout: ReadHeaderTimeout,
 		ReadTimeout:       ReadTimeout,
 		WriteTimeout:      WriteTimeout,
 		IdleTimeout:       IdleTimeout,
 	}
+}
 
-	// Create the errorgroup that will manage the server execution
-	eg, egCtx := errgroup.WithContext(ctx)
+// runServer starts the server in the errgroup and shuts it down gracefully
+// when the context is done.
+func runServer(ctx context.Context, eg *errgroup.Group, srv *http.Server) {
+	l := log.FromContext(ctx)
 
 	// Start the server
 	eg.Go(func() error {
-		l.Info("Starting server", slog.String("addr", srv.Addr))
+		l.Info("Starting server", slog.String("addr", srv.Addr), slog.Bool("tls", srv.TLSConfig != nil))
 
 		// Use the global context for the server
 		srv.BaseContext = func(_ net.Listener) context.Context {
-			return egCtx
+			return ctx
+		}
+
+		if srv.TLSConfig != nil {
+			// The certificates come from the TLS config
+			return srv.ListenAndServeTLS("", "")
 		}
 
 		return srv.ListenAndServe()
@@ -74,26 +278,18 @@ func StartServer(ctx context.Context, cfg *config.Config, fingers webfingers.Web
 	// Gracefully shutdown the server when the context is done
 	eg.Go(func() error {
 		// Wait for the context to be done
-		<-egCtx.Done()
+		<-ctx.Done()
 
-		l.Info("Shutting down server")
+		l.Info("Shutting down server", slog.String("addr", srv.Addr))
 		// Disable the cancel since we don't wan't to force
 		// the server to shutdown if the context is canceled.
-		noCancelCtx := context.WithoutCancel(egCtx)
+		noCancelCtx := context.WithoutCancel(ctx)
 
 		return srv.Shutdown(noCancelCtx)
 	})
 
 	// Log when the server is fully shutdown
 	srv.RegisterOnShutdown(func() {
-		l.Info("Server shutdown complete")
+		l.Info("Server shutdown complete", slog.String("addr", srv.Addr))
 	})
-
-	// Wait for the server to exit and check for errors that
-	// are not caused by the context being canceled.
-	if err := eg.Wait(); err != nil && ctx.Err() == nil {
-		return fmt.Errorf("server exited with error: %w", err)
-	}
-
-	return nil
 }
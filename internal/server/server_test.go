@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -15,6 +17,7 @@ import (
 
 	"git.maronato.dev/maronato/finger/internal/config"
 	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/metrics"
 	"git.maronato.dev/maronato/finger/internal/server"
 	"git.maronato.dev/maronato/finger/webfingers"
 )
@@ -79,6 +82,18 @@ func TestStartServer(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := config.NewConfig()
+		cfg.Port = fmt.Sprint(portGenerator())
+		cfg.ReloadInterval = -time.Second
+
+		ctx := log.WithLogger(context.Background(), log.NewLogger(&strings.Builder{}, cfg))
+
+		require.ErrorIs(t, server.StartServer(ctx, cfg, nil), config.ErrInvalidConfig)
+	})
+
 	t.Run("serves webfinger", func(t *testing.T) {
 		t.Parallel()
 
@@ -105,7 +120,7 @@ func TestStartServer(t *testing.T) {
 
 		go func() {
 			// Start the server
-			err := server.StartServer(ctx, cfg, fingers)
+			err := server.StartServer(ctx, cfg, webfingers.NewStore(fingers))
 			assert.NoError(t, err)
 		}()
 
@@ -131,6 +146,9 @@ func TestStartServer(t *testing.T) {
 		// Check the status code
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 
+		// CORS is enabled by default
+		require.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
 		// Check the response body
 		fingerGot := &webfingers.WebFinger{}
 
@@ -185,4 +203,194 @@ func TestStartServer(t *testing.T) {
 		// Check the status code
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 	})
+	t.Run("serves host-meta", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+
+		cfg := config.NewConfig()
+		l := log.NewLogger(&strings.Builder{}, cfg)
+
+		ctx = log.WithLogger(ctx, l)
+
+		// Use a new port
+		cfg.Port = fmt.Sprint(portGenerator())
+		cfg.PublicURL = "https://example.com"
+
+		go func() {
+			// Start the server
+			err := server.StartServer(ctx, cfg, nil)
+			assert.NoError(t, err)
+		}()
+
+		// Wait for the server to start
+		time.Sleep(time.Millisecond * 50)
+
+		// Create a new client
+		c := http.Client{}
+
+		for _, path := range []string{"/.well-known/host-meta", "/.well-known/host-meta.json"} {
+			// Create a new request
+			r, _ := http.NewRequestWithContext(ctx,
+				http.MethodGet,
+				"http://"+cfg.GetAddr()+path,
+				http.NoBody,
+			)
+
+			// Send the request
+			resp, err := c.Do(r)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			// Check the response
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, string(body), "https://example.com/.well-known/webfinger?resource={uri}")
+		}
+	})
+
+	t.Run("serves metrics", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+
+		cfg := config.NewConfig()
+		l := log.NewLogger(&strings.Builder{}, cfg)
+
+		ctx = log.WithLogger(ctx, l)
+		ctx = metrics.WithMetrics(ctx, metrics.New(server.Routes...))
+
+		// Use a new port
+		cfg.Port = fmt.Sprint(portGenerator())
+
+		go func() {
+			// Start the server
+			err := server.StartServer(ctx, cfg, nil)
+			assert.NoError(t, err)
+		}()
+
+		// Wait for the server to start
+		time.Sleep(time.Millisecond * 50)
+
+		// Create a new client
+		c := http.Client{}
+
+		for _, path := range []string{"/.well-known/webfinger?resource=acct:user@example.com", "/metrics"} {
+			// Create a new request
+			r, _ := http.NewRequestWithContext(ctx,
+				http.MethodGet,
+				"http://"+cfg.GetAddr()+path,
+				http.NoBody,
+			)
+
+			// Send the request
+			resp, err := c.Do(r)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			if path == "/metrics" {
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.Contains(t, string(body), `finger_http_requests_total{route="/.well-known/webfinger",status="404"} 1`)
+				require.Contains(t, string(body), `finger_lookups_total{result="miss"} 1`)
+			}
+		}
+	})
+
+	t.Run("serves metrics on a separate listener", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+
+		cfg := config.NewConfig()
+		l := log.NewLogger(&strings.Builder{}, cfg)
+
+		ctx = log.WithLogger(ctx, l)
+		ctx = metrics.WithMetrics(ctx, metrics.New(server.Routes...))
+
+		// Use new ports
+		cfg.Port = fmt.Sprint(portGenerator())
+		cfg.MetricsAddr = net.JoinHostPort(cfg.Host, fmt.Sprint(portGenerator()))
+
+		go func() {
+			// Start the server
+			err := server.StartServer(ctx, cfg, nil)
+			assert.NoError(t, err)
+		}()
+
+		// Wait for the server to start
+		time.Sleep(time.Millisecond * 50)
+
+		// Create a new client
+		c := http.Client{}
+
+		for addr, want := range map[string]int{
+			cfg.GetAddr():   http.StatusNotFound,
+			cfg.MetricsAddr: http.StatusOK,
+		} {
+			// Create a new request
+			r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/metrics", http.NoBody)
+
+			// Send the request
+			resp, err := c.Do(r)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			require.Equal(t, want, resp.StatusCode)
+		}
+	})
+	t.Run("serves the admin API on a separate listener", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+
+		cfg := config.NewConfig()
+		l := log.NewLogger(&strings.Builder{}, cfg)
+
+		ctx = log.WithLogger(ctx, l)
+
+		// Use new ports
+		cfg.Port = fmt.Sprint(portGenerator())
+		cfg.AdminAddr = net.JoinHostPort(cfg.Host, fmt.Sprint(portGenerator()))
+		cfg.AdminToken = "secret"
+
+		admin := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+
+		go func() {
+			// Start the server
+			err := server.StartServer(ctx, cfg, nil, server.WithAdmin(admin))
+			assert.NoError(t, err)
+		}()
+
+		// Wait for the server to start
+		time.Sleep(time.Millisecond * 50)
+
+		// Create a new client
+		c := http.Client{}
+
+		for addr, want := range map[string]int{
+			cfg.GetAddr(): http.StatusNotFound,
+			cfg.AdminAddr: http.StatusTeapot,
+		} {
+			// Create a new request
+			r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/resources", http.NoBody)
+
+			// Send the request
+			resp, err := c.Do(r)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			require.Equal(t, want, resp.StatusCode)
+		}
+	})
 }
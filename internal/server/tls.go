@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/filewatch"
+	"git.maronato.dev/maronato/finger/internal/log"
+)
+
+// certReloader holds a TLS certificate and reloads it when its files change,
+// so renewed certificates are used without a restart.
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	current atomic.Pointer[tls.Certificate]
+	// states holds the state of each file when they were last loaded.
+	states filewatch.States
+}
+
+// newCertReloader loads the certificate in the given files.
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	c := &certReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load reads the certificate files. If they can't be loaded, the current
+// certificate is kept.
+func (c *certReloader) load() error {
+	states := filewatch.Stat(c.certPath, c.keyPath)
+
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	c.current.Store(&cert)
+	c.states = states
+
+	return nil
+}
+
+// GetCertificate returns the current certificate. It is meant for tls.Config.
+func (c *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load(), nil
+}
+
+// watch reloads the certificate whenever its files change, checking them
+// every interval, until the context is done. Certificates that fail to load,
+// like when only one of the files was replaced yet, are retried on the next
+// check.
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !c.changed() {
+			continue
+		}
+
+		if err := c.load(); err != nil {
+			l.Error("Failed to reload TLS certificate, keeping the previous one", slog.Any("error", err))
+
+			continue
+		}
+
+		l.Info("Reloaded TLS certificate", slog.String("cert", c.certPath))
+	}
+}
+
+// changed reports whether any of the files changed since they were last loaded.
+func (c *certReloader) changed() bool {
+	return c.states.Changed(c.certPath, c.keyPath)
+}
+
+// tlsConfig returns the TLS config of the server, using certificates from certs.
+func tlsConfig(cfg *config.Config, certs *certReloader) *tls.Config {
+	minVersion, ok := config.TLSVersions[cfg.TLSMinVersion]
+	if !ok {
+		minVersion = config.TLSVersions[config.DefaultTLSMinVersion]
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: certs.GetCertificate,
+	}
+}
+
+// RedirectHandler redirects every request to the same URL over HTTPS on the
+// given port.
+func RedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// The host has no port
+			host = r.Host
+		}
+
+		// Leave the default port out of the URL
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
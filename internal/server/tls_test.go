@@ -0,0 +1,265 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/config"
+	"git.maronato.dev/maronato/finger/internal/log"
+	"git.maronato.dev/maronato/finger/internal/server"
+)
+
+// writeCert writes a self-signed certificate for localhost with the given
+// serial number to the config's TLS files.
+func writeCert(t *testing.T, cfg *config.Config, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	// Make sure the modification time changes even on coarse filesystems
+	modTime := time.Now()
+	if info, err := os.Stat(cfg.TLSCert); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+
+	for path, block := range map[string]*pem.Block{
+		cfg.TLSCert: {Type: "CERTIFICATE", Bytes: der},
+		cfg.TLSKey:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+}
+
+// newTLSConfig returns a config that serves HTTPS on a new port.
+func newTLSConfig(t *testing.T, port int) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	cfg := config.NewConfig()
+	cfg.Port = fmt.Sprint(port)
+	cfg.TLSCert = filepath.Join(dir, "cert.pem")
+	cfg.TLSKey = filepath.Join(dir, "key.pem")
+	cfg.ReloadInterval = 10 * time.Millisecond
+
+	writeCert(t, cfg, 1)
+
+	return cfg
+}
+
+// startTLSServer starts the server in the background until the test ends.
+func startTLSServer(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	ctx = log.WithLogger(ctx, log.NewLogger(&strings.Builder{}, cfg))
+
+	go func() {
+		assert.NoError(t, server.StartServer(ctx, cfg, nil))
+	}()
+
+	// Wait for the server to start
+	time.Sleep(time.Millisecond * 50)
+}
+
+// servedCert returns the certificate served at addr.
+func servedCert(t *testing.T, addr string, tlsCfg *tls.Config) (*x509.Certificate, error) {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, tlsCfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Only used in tests
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestStartServer_TLS(t *testing.T) {
+	t.Parallel()
+
+	portGenerator := getPortGenerator()
+
+	// Skip the ports used by the other tests
+	for range 100 {
+		portGenerator()
+	}
+
+	insecure := &tls.Config{InsecureSkipVerify: true} //nolint:gosec // Self-signed test certificates
+
+	t.Run("serves HTTPS", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		startTLSServer(t, cfg)
+
+		c := http.Client{Transport: &http.Transport{TLSClientConfig: insecure}}
+
+		resp, err := c.Get("https://" + cfg.GetAddr() + server.HealthPath) //nolint:noctx // Test request
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("reloads renewed certificates", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		startTLSServer(t, cfg)
+
+		cert, err := servedCert(t, cfg.GetAddr(), insecure)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), cert.SerialNumber.Int64())
+
+		writeCert(t, cfg, 2)
+
+		require.Eventually(t, func() bool {
+			cert, err := servedCert(t, cfg.GetAddr(), insecure)
+
+			return err == nil && cert.SerialNumber.Int64() == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("keeps the certificate if the new one is invalid", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		startTLSServer(t, cfg)
+
+		require.NoError(t, os.WriteFile(cfg.TLSCert, []byte("invalid"), 0o600))
+		time.Sleep(50 * time.Millisecond)
+
+		cert, err := servedCert(t, cfg.GetAddr(), insecure)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), cert.SerialNumber.Int64())
+	})
+
+	t.Run("enforces the minimum version", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		cfg.TLSMinVersion = "1.3"
+		startTLSServer(t, cfg)
+
+		old := insecure.Clone()
+		old.MaxVersion = tls.VersionTLS12
+
+		_, err := servedCert(t, cfg.GetAddr(), old)
+		require.Error(t, err)
+
+		_, err = servedCert(t, cfg.GetAddr(), insecure)
+		require.NoError(t, err)
+	})
+
+	t.Run("fails with invalid certificates", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		require.NoError(t, os.WriteFile(cfg.TLSKey, []byte("invalid"), 0o600))
+
+		ctx := log.WithLogger(t.Context(), log.NewLogger(&strings.Builder{}, cfg))
+
+		require.Error(t, server.StartServer(ctx, cfg, nil))
+	})
+
+	t.Run("redirects HTTP to HTTPS", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTLSConfig(t, portGenerator())
+		cfg.RedirectAddr = net.JoinHostPort(cfg.Host, fmt.Sprint(portGenerator()))
+		startTLSServer(t, cfg)
+
+		c := http.Client{
+			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err := c.Get("http://" + cfg.RedirectAddr + "/.well-known/webfinger?resource=acct:user@localhost") //nolint:noctx // Test request
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+		require.Equal(t,
+			"https://localhost:"+cfg.Port+"/.well-known/webfinger?resource=acct:user@localhost",
+			resp.Header.Get("Location"),
+		)
+	})
+}
+
+func TestRedirectHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		port   string
+		target string
+		want   string
+	}{
+		{
+			name:   "keeps the path and query",
+			port:   "443",
+			target: "http://example.com/.well-known/webfinger?resource=acct:user@example.com",
+			want:   "https://example.com/.well-known/webfinger?resource=acct:user@example.com",
+		},
+		{
+			name:   "replaces the port",
+			port:   "8443",
+			target: "http://example.com:8080/",
+			want:   "https://example.com:8443/",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.target, http.NoBody)
+
+			server.RedirectHandler(tc.port).ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusPermanentRedirect, w.Code)
+			require.Equal(t, tc.want, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+-- Schema of the Finger database. Every resource has a subject and,
+-- optionally, aliases, links and properties. Subjects and aliases are stored
+-- normalized (e.g. "acct:user@example.com"), the same way the server
+-- normalizes requested resources.
+
+-- finger_resources holds one row per webfinger.
+CREATE TABLE IF NOT EXISTS finger_resources (
+    id INTEGER PRIMARY KEY,
+    subject TEXT NOT NULL UNIQUE,
+    -- How long the webfinger may be cached, in seconds. 0 uses the server default.
+    max_age INTEGER NOT NULL DEFAULT 0,
+    -- When the webfinger last changed, in seconds since the Unix epoch.
+    updated_at INTEGER NOT NULL DEFAULT 0
+);
+
+-- finger_aliases holds the other names of a resource. Resources are looked up
+-- by subject before aliases.
+CREATE TABLE IF NOT EXISTS finger_aliases (
+    alias TEXT PRIMARY KEY,
+    resource_id INTEGER NOT NULL REFERENCES finger_resources (id),
+    position INTEGER NOT NULL
+);
+
+-- finger_links holds the links of a resource, in order. Titles and
+-- properties are JSON objects of strings, or empty.
+CREATE TABLE IF NOT EXISTS finger_links (
+    resource_id INTEGER NOT NULL REFERENCES finger_resources (id),
+    position INTEGER NOT NULL,
+    rel TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT '',
+    href TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    titles TEXT NOT NULL DEFAULT '',
+    properties TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (resource_id, position)
+);
+
+-- finger_properties holds the properties of a resource.
+CREATE TABLE IF NOT EXISTS finger_properties (
+    resource_id INTEGER NOT NULL REFERENCES finger_resources (id),
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (resource_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS finger_aliases_resource_id ON finger_aliases (resource_id);
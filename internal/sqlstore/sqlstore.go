@@ -0,0 +1,506 @@
+// Package sqlstore stores webfingers in a SQL database and resolves resources
+// by querying it, so large sets of resources don't have to be kept in memory.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	// Register the pure-Go SQLite driver.
+	_ "modernc.org/sqlite"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+// DriverName is the name of the bundled SQLite driver.
+const DriverName = "sqlite"
+
+// Schema creates the tables used by the store. It can be run more than once.
+//
+//go:embed schema.sql
+var Schema string
+
+// ErrPattern is returned when importing patterns, which can't be stored in
+// the database since it only resolves exact resources.
+var ErrPattern = errors.New("patterns can't be stored in the database")
+
+// Store resolves resources from a SQL database.
+type Store struct {
+	db         *sql.DB
+	normalizer webfingers.Normalizer
+}
+
+// queryer runs queries on a database or in a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// New creates a store that uses db. Imported subjects and aliases are
+// normalized by normalizer, which should be the one used by the server.
+func New(db *sql.DB, normalizer webfingers.Normalizer) *Store {
+	return &Store{db: db, normalizer: normalizer}
+}
+
+// Open opens the SQLite database at path, creating it and its schema if needed.
+func Open(ctx context.Context, path string, normalizer webfingers.Normalizer) (*Store, error) {
+	db, err := sql.Open(DriverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	s := New(db, normalizer)
+
+	if err := s.CreateSchema(ctx); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CreateSchema creates the tables used by the store if they don't exist.
+func (s *Store) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("error creating database schema: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close() //nolint:wrapcheck // Nothing to add
+}
+
+// Count returns the number of resources in the database.
+func (s *Store) Count(ctx context.Context) (int, error) {
+	var count int
+
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM finger_resources").Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting resources: %w", err)
+	}
+
+	return count, nil
+}
+
+// Resolve returns the webfinger whose subject or alias is the resource.
+// Database errors are returned as webfingers.ErrUnavailable.
+func (s *Store) Resolve(ctx context.Context, resource string) (*webfingers.WebFinger, error) {
+	finger, err := resolve(ctx, s.db, resource)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("%w: %s", webfingers.ErrNotFound, resource)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", webfingers.ErrUnavailable, err)
+	}
+
+	return finger, nil
+}
+
+// resolve reads the webfinger of a resource. Subjects take precedence over aliases.
+func resolve(ctx context.Context, q queryer, resource string) (*webfingers.WebFinger, error) {
+	var (
+		id        int64
+		maxAge    int64
+		updatedAt int64
+		finger    webfingers.WebFinger
+	)
+
+	err := q.QueryRowContext(ctx, `
+		SELECT id, subject, max_age, updated_at, 0 AS alias FROM finger_resources WHERE subject = ?
+		UNION ALL
+		SELECT r.id, r.subject, r.max_age, r.updated_at, 1 AS alias FROM finger_resources r
+		JOIN finger_aliases a ON a.resource_id = r.id WHERE a.alias = ?
+		ORDER BY alias LIMIT 1`,
+		resource, resource,
+	).Scan(&id, &finger.Subject, &maxAge, &updatedAt, new(int))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Wrapped by the caller
+	}
+
+	finger.MaxAge = time.Duration(maxAge) * time.Second
+	if updatedAt > 0 {
+		finger.ModTime = time.Unix(updatedAt, 0)
+	}
+
+	if finger.Aliases, err = readAliases(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	if finger.Links, err = readLinks(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	if finger.Properties, err = readProperties(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	return &finger, nil
+}
+
+func readAliases(ctx context.Context, q queryer, id int64) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT alias FROM finger_aliases WHERE resource_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []string
+
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("error reading aliases: %w", err)
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err() //nolint:wrapcheck // Wrapped by the caller
+}
+
+func readLinks(ctx context.Context, q queryer, id int64) ([]webfingers.Link, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT rel, type, href, template, titles, properties FROM finger_links
+		WHERE resource_id = ? ORDER BY position`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []webfingers.Link
+
+	for rows.Next() {
+		var (
+			link               webfingers.Link
+			titles, properties string
+		)
+
+		if err := rows.Scan(&link.Rel, &link.Type, &link.Href, &link.Template, &titles, &properties); err != nil {
+			return nil, fmt.Errorf("error reading links: %w", err)
+		}
+
+		if link.Titles, err = decodeMap(titles); err != nil {
+			return nil, fmt.Errorf("error reading titles of link %s: %w", link.Rel, err)
+		}
+
+		if link.Properties, err = decodeMap(properties); err != nil {
+			return nil, fmt.Errorf("error reading properties of link %s: %w", link.Rel, err)
+		}
+
+		links = append(links, link)
+	}
+
+	return links, rows.Err() //nolint:wrapcheck // Wrapped by the caller
+}
+
+func readProperties(ctx context.Context, q queryer, id int64) (map[string]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name, value FROM finger_properties WHERE resource_id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading properties: %w", err)
+	}
+	defer rows.Close()
+
+	var properties map[string]string
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("error reading properties: %w", err)
+		}
+
+		if properties == nil {
+			properties = make(map[string]string)
+		}
+
+		properties[name] = value
+	}
+
+	return properties, rows.Err() //nolint:wrapcheck // Wrapped by the caller
+}
+
+// decodeMap decodes a JSON object of strings. Empty values are nil maps.
+func decodeMap(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil //nolint:nilnil // An empty value is a nil map
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	return m, nil
+}
+
+// encodeMap encodes a map as a JSON object. Empty maps are empty values.
+func encodeMap(m map[string]string) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Import writes the webfingers into the database in a single transaction,
+// replacing the resources that share their subject. Resources that didn't
+// change keep their modification time. If prune is set, resources that are
+// not in fingers are deleted. It returns the number of imported resources.
+func (s *Store) Import(ctx context.Context, fingers webfingers.WebFingers, prune bool) (int, error) {
+	imported, err := s.prepare(fingers)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // Rolling back after a commit is a no-op
+
+	// Keep the modification time of unchanged resources
+	previous := make(webfingers.WebFingers, len(imported))
+
+	for subject := range imported {
+		finger, err := resolve(ctx, tx, subject)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			continue
+		case err != nil:
+			return 0, fmt.Errorf("error reading %s: %w", subject, err)
+		case finger.Subject == subject:
+			previous[subject] = finger
+		}
+	}
+
+	// Changed resources are left without a modification time
+	imported.SetModTimes(previous, time.Time{})
+
+	var changed []*webfingers.WebFinger
+
+	for _, subject := range slices.Sorted(maps.Keys(imported)) {
+		if finger := imported[subject]; finger.ModTime.IsZero() {
+			changed = append(changed, finger)
+		}
+	}
+
+	// Delete every changed resource first so aliases can move between them
+	for _, finger := range changed {
+		if err := deleteResource(ctx, tx, finger.Subject); err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+
+	for _, finger := range changed {
+		finger.ModTime = now
+
+		if err := insertResource(ctx, tx, finger); err != nil {
+			return 0, fmt.Errorf("error writing %s: %w", finger.Subject, err)
+		}
+	}
+
+	if prune {
+		if err := pruneResources(ctx, tx, imported); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(imported), nil
+}
+
+// prepare returns the webfingers keyed by their normalized subject, as they
+// are read back from the database.
+func (s *Store) prepare(fingers webfingers.WebFingers) (webfingers.WebFingers, error) {
+	prepared := make(webfingers.WebFingers)
+	seen := make(map[*webfingers.WebFinger]bool)
+
+	for _, finger := range fingers {
+		// Aliases point to the same webfinger as their subject
+		if seen[finger] {
+			continue
+		}
+
+		seen[finger] = true
+
+		if webfingers.IsPattern(finger.Subject) {
+			return nil, fmt.Errorf("%w: %s", ErrPattern, finger.Subject)
+		}
+
+		stored := &webfingers.WebFinger{
+			MaxAge:     finger.MaxAge.Truncate(time.Second),
+			Properties: nilIfEmpty(finger.Properties),
+		}
+
+		var err error
+		if stored.Subject, err = s.normalizer.Normalize(finger.Subject); err != nil {
+			return nil, fmt.Errorf("error normalizing %s: %w", finger.Subject, err)
+		}
+
+		for _, alias := range finger.Aliases {
+			if webfingers.IsPattern(alias) {
+				return nil, fmt.Errorf("%w: alias %s of %s", ErrPattern, alias, finger.Subject)
+			}
+
+			normalized, err := s.normalizer.Normalize(alias)
+			if err != nil {
+				return nil, fmt.Errorf("error normalizing alias %s of %s: %w", alias, finger.Subject, err)
+			}
+
+			stored.Aliases = append(stored.Aliases, normalized)
+		}
+
+		for _, link := range finger.Links {
+			link.Titles = nilIfEmpty(link.Titles)
+			link.Properties = nilIfEmpty(link.Properties)
+			stored.Links = append(stored.Links, link)
+		}
+
+		if other, ok := prepared[stored.Subject]; ok {
+			return nil, fmt.Errorf("%w: %s and %s", webfingers.ErrDuplicateResource, other.Subject, finger.Subject)
+		}
+
+		prepared[stored.Subject] = stored
+	}
+
+	return prepared, nil
+}
+
+func nilIfEmpty(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// deleteResource deletes a resource and everything that belongs to it.
+func deleteResource(ctx context.Context, tx *sql.Tx, subject string) error {
+	const owned = "resource_id IN (SELECT id FROM finger_resources WHERE subject = ?)"
+
+	for _, query := range []string{
+		"DELETE FROM finger_aliases WHERE " + owned,
+		"DELETE FROM finger_links WHERE " + owned,
+		"DELETE FROM finger_properties WHERE " + owned,
+		"DELETE FROM finger_resources WHERE subject = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, subject); err != nil {
+			return fmt.Errorf("error deleting %s: %w", subject, err)
+		}
+	}
+
+	return nil
+}
+
+// insertResource inserts a resource and everything that belongs to it.
+func insertResource(ctx context.Context, tx *sql.Tx, finger *webfingers.WebFinger) error {
+	var id int64
+
+	err := tx.QueryRowContext(ctx,
+		"INSERT INTO finger_resources (subject, max_age, updated_at) VALUES (?, ?, ?) RETURNING id",
+		finger.Subject, int64(finger.MaxAge/time.Second), finger.ModTime.Unix(),
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("error inserting resource: %w", err)
+	}
+
+	for i, alias := range finger.Aliases {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO finger_aliases (alias, resource_id, position) VALUES (?, ?, ?)",
+			alias, id, i,
+		); err != nil {
+			return fmt.Errorf("error inserting alias %s: %w", alias, err)
+		}
+	}
+
+	for i, link := range finger.Links {
+		titles, err := encodeMap(link.Titles)
+		if err != nil {
+			return err
+		}
+
+		properties, err := encodeMap(link.Properties)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO finger_links (resource_id, position, rel, type, href, template, titles, properties)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, i, link.Rel, link.Type, link.Href, link.Template, titles, properties,
+		); err != nil {
+			return fmt.Errorf("error inserting link %s: %w", link.Rel, err)
+		}
+	}
+
+	for name, value := range finger.Properties {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO finger_properties (resource_id, name, value) VALUES (?, ?, ?)",
+			id, name, value,
+		); err != nil {
+			return fmt.Errorf("error inserting property %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// pruneResources deletes the resources that are not in keep.
+func pruneResources(ctx context.Context, tx *sql.Tx, keep webfingers.WebFingers) error {
+	rows, err := tx.QueryContext(ctx, "SELECT subject FROM finger_resources")
+	if err != nil {
+		return fmt.Errorf("error listing resources: %w", err)
+	}
+
+	var stale []string
+
+	for rows.Next() {
+		var subject string
+		if err := rows.Scan(&subject); err != nil {
+			rows.Close()
+
+			return fmt.Errorf("error listing resources: %w", err)
+		}
+
+		if _, ok := keep[subject]; !ok {
+			stale = append(stale, subject)
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error listing resources: %w", err)
+	}
+
+	for _, subject := range stale {
+		if err := deleteResource(ctx, tx, subject); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
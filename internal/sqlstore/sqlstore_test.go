@@ -0,0 +1,210 @@
+package sqlstore_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/internal/sqlstore"
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func newFingers(t *testing.T, resources webfingers.Resources) webfingers.WebFingers {
+	t.Helper()
+
+	fingers, err := webfingers.NewWebFingers(resources, webfingers.URNAliases{"name": "http://schema/name"})
+	require.NoError(t, err)
+
+	return fingers
+}
+
+func openStore(t *testing.T, normalizer webfingers.Normalizer) (*sqlstore.Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "finger.db")
+
+	store, err := sqlstore.Open(t.Context(), path, normalizer)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { store.Close() })
+
+	return store, path
+}
+
+func TestStore_Resolve(t *testing.T) {
+	t.Parallel()
+
+	store, _ := openStore(t, webfingers.DefaultNormalizer)
+
+	fingers := newFingers(t, webfingers.Resources{
+		"alice@example.com": {
+			"name":                            "Alice",
+			"aliases":                         "https://example.com/@alice https://example.com/users/alice",
+			"max_age":                         "1h",
+			"http://webfinger.net/rel/avatar": "https://example.com/alice.png",
+		},
+	})
+	fingers["acct:alice@example.com"].Links[0].Titles = map[string]string{"en": "Avatar"}
+
+	count, err := store.Import(t.Context(), fingers, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	tests := []struct {
+		name     string
+		resource string
+		wantErr  error
+	}{
+		{
+			name:     "resolves subjects",
+			resource: "acct:alice@example.com",
+		},
+		{
+			name:     "resolves aliases",
+			resource: "https://example.com/users/alice",
+		},
+		{
+			name:     "returns not found for unknown resources",
+			resource: "acct:bob@example.com",
+			wantErr:  webfingers.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			finger, err := store.Resolve(t.Context(), tc.resource)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "acct:alice@example.com", finger.Subject)
+			require.Equal(t, []string{"https://example.com/@alice", "https://example.com/users/alice"}, finger.Aliases)
+			require.Equal(t, map[string]string{"http://schema/name": "Alice"}, finger.Properties)
+			require.Equal(t, []webfingers.Link{{
+				Rel:    "http://webfinger.net/rel/avatar",
+				Href:   "https://example.com/alice.png",
+				Titles: map[string]string{"en": "Avatar"},
+			}}, finger.Links)
+			require.Equal(t, time.Hour, finger.MaxAge)
+			require.False(t, finger.ModTime.IsZero())
+		})
+	}
+}
+
+func TestStore_Resolve_Closed(t *testing.T) {
+	t.Parallel()
+
+	store, _ := openStore(t, webfingers.DefaultNormalizer)
+	require.NoError(t, store.Close())
+
+	_, err := store.Resolve(t.Context(), "acct:alice@example.com")
+	require.ErrorIs(t, err, webfingers.ErrUnavailable)
+}
+
+func TestStore_Import(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps modification times of unchanged resources", func(t *testing.T) {
+		t.Parallel()
+
+		store, path := openStore(t, webfingers.DefaultNormalizer)
+
+		resources := webfingers.Resources{
+			"alice@example.com": {"name": "Alice"},
+			"bob@example.com":   {"name": "Bob"},
+		}
+
+		_, err := store.Import(t.Context(), newFingers(t, resources), false)
+		require.NoError(t, err)
+
+		// Pretend the resources were imported a while ago
+		db, err := sql.Open(sqlstore.DriverName, path)
+		require.NoError(t, err)
+
+		defer db.Close()
+
+		old := time.Unix(1000, 0)
+		_, err = db.ExecContext(t.Context(), "UPDATE finger_resources SET updated_at = ?", old.Unix())
+		require.NoError(t, err)
+
+		resources["bob@example.com"]["name"] = "Robert"
+
+		_, err = store.Import(t.Context(), newFingers(t, resources), false)
+		require.NoError(t, err)
+
+		alice, err := store.Resolve(t.Context(), "acct:alice@example.com")
+		require.NoError(t, err)
+		require.Equal(t, old, alice.ModTime)
+
+		bob, err := store.Resolve(t.Context(), "acct:bob@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "Robert", bob.Properties["http://schema/name"])
+		require.True(t, bob.ModTime.After(old))
+	})
+
+	t.Run("replaces resources and prunes the rest", func(t *testing.T) {
+		t.Parallel()
+
+		store, _ := openStore(t, webfingers.DefaultNormalizer)
+
+		_, err := store.Import(t.Context(), newFingers(t, webfingers.Resources{
+			"alice@example.com": {"aliases": "https://example.com/@alice"},
+			"bob@example.com":   {"name": "Bob"},
+		}), false)
+		require.NoError(t, err)
+
+		// Moving an alias between resources works
+		_, err = store.Import(t.Context(), newFingers(t, webfingers.Resources{
+			"alice@example.com": {"name": "Alice"},
+			"aaron@example.com": {"aliases": "https://example.com/@alice"},
+		}), true)
+		require.NoError(t, err)
+
+		count, err := store.Count(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+
+		_, err = store.Resolve(t.Context(), "acct:bob@example.com")
+		require.ErrorIs(t, err, webfingers.ErrNotFound)
+
+		finger, err := store.Resolve(t.Context(), "https://example.com/@alice")
+		require.NoError(t, err)
+		require.Equal(t, "acct:aaron@example.com", finger.Subject)
+	})
+
+	t.Run("normalizes subjects and aliases", func(t *testing.T) {
+		t.Parallel()
+
+		store, _ := openStore(t, webfingers.Normalizer{IgnoreLocalCase: true})
+
+		_, err := store.Import(t.Context(), newFingers(t, webfingers.Resources{
+			"Alice@example.com": {"aliases": "acct:Ally@example.com"},
+		}), false)
+		require.NoError(t, err)
+
+		finger, err := store.Resolve(t.Context(), "acct:ally@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "acct:alice@example.com", finger.Subject)
+	})
+
+	t.Run("errors on patterns", func(t *testing.T) {
+		t.Parallel()
+
+		store, _ := openStore(t, webfingers.DefaultNormalizer)
+
+		_, err := store.Import(t.Context(), newFingers(t, webfingers.Resources{
+			"*@example.com": {"name": "Someone"},
+		}), false)
+		require.ErrorIs(t, err, sqlstore.ErrPattern)
+	})
+}
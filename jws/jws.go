@@ -0,0 +1,330 @@
+// Package jws signs documents with JSON Web Signatures (RFC 7515) and
+// verifies them, so clients can check that a webfinger came from the server
+// that signed it even when it is served by a cache or a mirror.
+//
+// Signatures are made over the exact bytes of a document, either detached
+// (sent apart from the document, like in a header) or in the flattened JSON
+// serialization, served as application/jose+json.
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// ErrNoSigningKey is returned when a signer has no private key to sign with.
+	ErrNoSigningKey = errors.New("no signing key")
+	// ErrDuplicateKey is returned when two keys have the same ID.
+	ErrDuplicateKey = errors.New("duplicate key ID")
+	// ErrMalformed is returned when a signature can't be parsed.
+	ErrMalformed = errors.New("malformed JWS")
+	// ErrUnknownKey is returned when no key can verify a signature.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInvalidSignature is returned when a signature doesn't match the document.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Header is the protected header of a JWS.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	// ContentType is the media type of the payload, without "application/".
+	ContentType string `json:"cty,omitempty"`
+	// Critical lists extensions that must be understood. None are
+	// supported, so signatures that use any are rejected.
+	Critical []string `json:"crit,omitempty"`
+}
+
+// JSON is a JWS in the flattened JSON serialization.
+type JSON struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Signature string `json:"signature"`
+}
+
+// Signer signs documents with its first key and publishes every key, so
+// clients can still verify documents signed with keys being rotated out.
+// Keys can be replaced at any time.
+type Signer struct {
+	keys atomic.Pointer[[]*Key]
+}
+
+// NewSigner creates a signer that signs with the first key, which must be
+// private.
+func NewSigner(keys ...*Key) (*Signer, error) {
+	s := &Signer{}
+
+	if err := s.SetKeys(keys...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SetKeys replaces the keys of the signer. If they can't be used, the
+// current keys are kept.
+func (s *Signer) SetKeys(keys ...*Key) error {
+	if len(keys) == 0 || !keys[0].Private() {
+		return fmt.Errorf("%w: the first key must be a private key", ErrNoSigningKey)
+	}
+
+	seen := make(map[string]bool, len(keys))
+
+	for _, k := range keys {
+		if seen[k.ID] {
+			return fmt.Errorf("%w: %s", ErrDuplicateKey, k.ID)
+		}
+
+		seen[k.ID] = true
+	}
+
+	keys = slices.Clone(keys)
+	s.keys.Store(&keys)
+
+	return nil
+}
+
+// Keys returns the public parts of the keys of the signer.
+func (s *Signer) Keys() []*Key {
+	keys := *s.keys.Load()
+	public := make([]*Key, 0, len(keys))
+
+	for _, k := range keys {
+		public = append(public, k.Public())
+	}
+
+	return public
+}
+
+// KeyID returns the ID of the key documents are signed with.
+func (s *Signer) KeyID() string {
+	return (*s.keys.Load())[0].ID
+}
+
+// KeySet returns the public keys of the signer as a JWK Set.
+func (s *Signer) KeySet() KeySet {
+	return NewKeySet(*s.keys.Load()...)
+}
+
+// SignDetached signs the payload and returns a compact JWS without the
+// payload (RFC 7515, appendix F). contentType is the media type of the
+// payload, like "jrd+json".
+func (s *Signer) SignDetached(payload []byte, contentType string) (string, error) {
+	protected, signature, err := s.sign(payload, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	return protected + ".." + signature, nil
+}
+
+// SignJSON signs the payload and returns a JWS in the flattened JSON
+// serialization. contentType is the media type of the payload.
+func (s *Signer) SignJSON(payload []byte, contentType string) ([]byte, error) {
+	protected, signature, err := s.sign(payload, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(JSON{Payload: encode(payload), Protected: protected, Signature: signature})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JWS: %w", err)
+	}
+
+	return data, nil
+}
+
+// sign returns the encoded protected header and signature over the payload.
+func (s *Signer) sign(payload []byte, contentType string) (string, string, error) {
+	key := (*s.keys.Load())[0]
+
+	header, err := json.Marshal(Header{Algorithm: key.Algorithm, KeyID: key.ID, ContentType: contentType})
+	if err != nil {
+		return "", "", fmt.Errorf("error encoding JWS header: %w", err)
+	}
+
+	protected := encode(header)
+
+	signature, err := key.sign([]byte(protected + "." + encode(payload)))
+	if err != nil {
+		return "", "", err
+	}
+
+	return protected, encode(signature), nil
+}
+
+// VerifyDetached verifies a detached compact JWS over the payload with
+// whichever of the keys signed it, and returns that key.
+func VerifyDetached(signature string, payload []byte, keys []*Key) (*Key, error) {
+	parts := strings.Split(signature, ".")
+	if len(parts) != 3 { //nolint:mnd // Header, payload and signature
+		return nil, fmt.Errorf("%w: expected 3 parts, got %d", ErrMalformed, len(parts))
+	}
+
+	if parts[1] != "" {
+		return nil, fmt.Errorf("%w: the payload is not detached", ErrMalformed)
+	}
+
+	return verify(parts[0], encode(payload), parts[2], keys)
+}
+
+// VerifyJSON verifies a JWS in the flattened JSON serialization with
+// whichever of the keys signed it, and returns its payload and that key.
+func VerifyJSON(data []byte, keys []*Key) ([]byte, *Key, error) {
+	var doc JSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	key, err := verify(doc.Protected, doc.Payload, doc.Signature, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := decode("payload", doc.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return payload, key, nil
+}
+
+// verify checks the signature over the encoded header and payload.
+func verify(protected, payload, signature string, keys []*Key) (*Key, error) {
+	data, err := decode("protected header", protected)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %w", ErrMalformed, err)
+	}
+
+	if len(header.Critical) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical headers %v", ErrMalformed, header.Critical)
+	}
+
+	sig, err := decode("signature", signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	input := []byte(protected + "." + payload)
+	found := false
+
+	for _, k := range keys {
+		// The algorithm must be the key's, so a key is never used with
+		// an algorithm chosen by whoever made the signature
+		if k.Algorithm != header.Algorithm || (header.KeyID != "" && k.ID != header.KeyID) {
+			continue
+		}
+
+		found = true
+
+		if k.verify(input, sig) {
+			return k, nil
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s key %q", ErrUnknownKey, header.Algorithm, header.KeyID)
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+// hash returns the hash used by the algorithm of the key, if any.
+func (k *Key) hash() (crypto.Hash, hash.Hash) {
+	switch k.Algorithm {
+	case ES256, RS256:
+		return crypto.SHA256, sha256.New()
+	case ES384:
+		return crypto.SHA384, sha512.New384()
+	case ES512:
+		return crypto.SHA512, sha512.New()
+	default:
+		return 0, nil
+	}
+}
+
+// digest returns the hash of the input, or the input itself for algorithms
+// that hash it themselves.
+func (k *Key) digest(input []byte) (crypto.Hash, []byte) {
+	h, hh := k.hash()
+	if hh == nil {
+		return h, input
+	}
+
+	hh.Write(input)
+
+	return h, hh.Sum(nil)
+}
+
+// sign signs the input.
+func (k *Key) sign(input []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, fmt.Errorf("%w: %s is a public key", ErrNoSigningKey, k.ID)
+	}
+
+	h, digest := k.digest(input)
+
+	if priv, ok := k.private.(*ecdsa.PrivateKey); ok {
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+		if err != nil {
+			return nil, fmt.Errorf("error signing: %w", err)
+		}
+
+		// JWS uses fixed-size R || S instead of ASN.1
+		size := (priv.Curve.Params().BitSize + 7) / 8 //nolint:mnd // Bits to bytes
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+
+		return sig, nil
+	}
+
+	sig, err := k.private.Sign(rand.Reader, digest, h)
+	if err != nil {
+		return nil, fmt.Errorf("error signing: %w", err)
+	}
+
+	return sig, nil
+}
+
+// verify reports whether sig is a signature of the input.
+func (k *Key) verify(input, sig []byte) bool {
+	h, digest := k.digest(input)
+
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, digest, sig)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8 //nolint:mnd // Bits to bytes
+		if len(sig) != 2*size {
+			return false
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+
+		return ecdsa.Verify(pub, digest, r, s)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, h, digest, sig) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,226 @@
+package jws_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+// newSigner creates a signer with a new key for the algorithm.
+func newSigner(t *testing.T, alg string) (*jws.Signer, *jws.Key) {
+	t.Helper()
+
+	k, err := jws.NewKey(generateKeys(t)[alg], "")
+	require.NoError(t, err)
+
+	s, err := jws.NewSigner(k)
+	require.NoError(t, err)
+
+	return s, k
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"subject":"acct:user@example.com"}` + "\n")
+
+	for alg, signer := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			k, err := jws.NewKey(signer, "")
+			require.NoError(t, err)
+
+			s, err := jws.NewSigner(k)
+			require.NoError(t, err)
+			require.Equal(t, k.ID, s.KeyID())
+
+			sig, err := s.SignDetached(payload, "jrd+json")
+			require.NoError(t, err)
+
+			protected, _, _ := strings.Cut(sig, ".")
+			header, err := base64.RawURLEncoding.DecodeString(protected)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"alg": "`+alg+`", "kid": "`+k.ID+`", "cty": "jrd+json"}`, string(header))
+
+			got, err := jws.VerifyDetached(sig, payload, s.Keys())
+			require.NoError(t, err)
+			require.Equal(t, k.ID, got.ID)
+
+			doc, err := s.SignJSON(payload, "jrd+json")
+			require.NoError(t, err)
+
+			verified, got, err := jws.VerifyJSON(doc, s.Keys())
+			require.NoError(t, err)
+			require.Equal(t, payload, verified)
+			require.Equal(t, k.ID, got.ID)
+		})
+	}
+}
+
+func TestSigner_SetKeys(t *testing.T) {
+	t.Parallel()
+
+	s, old := newSigner(t, jws.EdDSA)
+
+	sig, err := s.SignDetached([]byte("payload"), "")
+	require.NoError(t, err)
+
+	newKey, err := jws.NewKey(generateKeys(t)[jws.ES256], "")
+	require.NoError(t, err)
+
+	// Rotate, publishing the old key until its signatures expire
+	require.NoError(t, s.SetKeys(newKey, old.Public()))
+	require.Len(t, s.KeySet().Keys, 2)
+
+	_, err = jws.VerifyDetached(sig, []byte("payload"), s.Keys())
+	require.NoError(t, err)
+
+	sig, err = s.SignDetached([]byte("payload"), "")
+	require.NoError(t, err)
+
+	got, err := jws.VerifyDetached(sig, []byte("payload"), s.Keys())
+	require.NoError(t, err)
+	require.Equal(t, newKey.ID, got.ID)
+
+	// Invalid keys are rejected and the current ones are kept
+	require.ErrorIs(t, s.SetKeys(), jws.ErrNoSigningKey)
+	require.ErrorIs(t, s.SetKeys(old.Public(), newKey), jws.ErrNoSigningKey)
+	require.ErrorIs(t, s.SetKeys(newKey, newKey.Public()), jws.ErrDuplicateKey)
+	require.Len(t, s.Keys(), 2)
+	require.Equal(t, newKey.ID, s.Keys()[0].ID)
+
+	// The published keys are only public
+	data, err := json.Marshal(s.KeySet())
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"d"`)
+}
+
+func TestVerifyDetached(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newSigner(t, jws.EdDSA)
+	other, _ := newSigner(t, jws.EdDSA)
+	ecSigner, _ := newSigner(t, jws.ES256)
+
+	payload := []byte("payload")
+
+	sig, err := s.SignDetached(payload, "")
+	require.NoError(t, err)
+
+	protected, _, _ := strings.Cut(sig, ".")
+
+	// A header that claims another algorithm for the same key
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"` + s.Keys()[0].ID + `"}`))
+	crit := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","crit":["b64"]}`))
+
+	tests := []struct {
+		name    string
+		sig     string
+		payload []byte
+		keys    []*jws.Key
+		wantErr error
+	}{
+		{name: "valid signature", sig: sig, payload: payload, keys: s.Keys()},
+		{
+			name:    "valid signature among other keys",
+			sig:     sig,
+			payload: payload,
+			keys:    append(ecSigner.Keys(), s.Keys()...),
+		},
+		{name: "changed payload", sig: sig, payload: []byte("changed"), keys: s.Keys(), wantErr: jws.ErrInvalidSignature},
+		{name: "unknown key", sig: sig, payload: payload, keys: other.Keys(), wantErr: jws.ErrUnknownKey},
+		{
+			name:    "other algorithm",
+			sig:     strings.Replace(sig, protected, forged, 1),
+			payload: payload,
+			keys:    s.Keys(),
+			wantErr: jws.ErrUnknownKey,
+		},
+		{
+			name:    "critical headers",
+			sig:     strings.Replace(sig, protected, crit, 1),
+			payload: payload,
+			keys:    s.Keys(),
+			wantErr: jws.ErrMalformed,
+		},
+		{
+			name:    "attached payload",
+			sig:     strings.Replace(sig, "..", ".cGF5bG9hZA.", 1),
+			payload: payload,
+			keys:    s.Keys(),
+			wantErr: jws.ErrMalformed,
+		},
+		{name: "not a JWS", sig: "invalid", payload: payload, keys: s.Keys(), wantErr: jws.ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := jws.VerifyDetached(tc.sig, tc.payload, tc.keys)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("verifies RFC 8037 signatures", func(t *testing.T) {
+		t.Parallel()
+
+		// The example from RFC 8037, appendix A.4
+		k, err := jws.JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+		}.Key()
+		require.NoError(t, err)
+
+		payload, got, err := jws.VerifyJSON([]byte(`{
+			"protected": "eyJhbGciOiJFZERTQSJ9",
+			"payload": "RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc",
+			"signature": "hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+		}`), []*jws.Key{k})
+		require.NoError(t, err)
+		require.Equal(t, "Example of Ed25519 signing", string(payload))
+		require.Equal(t, k, got)
+	})
+
+	t.Run("rejects changed payloads", func(t *testing.T) {
+		t.Parallel()
+
+		s, _ := newSigner(t, jws.ES256)
+
+		data, err := s.SignJSON([]byte("payload"), "")
+		require.NoError(t, err)
+
+		var doc jws.JSON
+		require.NoError(t, json.Unmarshal(data, &doc))
+
+		doc.Payload = base64.RawURLEncoding.EncodeToString([]byte("changed"))
+
+		data, err = json.Marshal(doc)
+		require.NoError(t, err)
+
+		_, _, err = jws.VerifyJSON(data, s.Keys())
+		require.ErrorIs(t, err, jws.ErrInvalidSignature)
+
+		_, _, err = jws.VerifyJSON([]byte("not json"), s.Keys())
+		require.ErrorIs(t, err, jws.ErrMalformed)
+	})
+}
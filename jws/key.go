@@ -0,0 +1,474 @@
+package jws
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Algorithms supported for signing and verifying.
+const (
+	EdDSA = "EdDSA"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+	RS256 = "RS256"
+)
+
+// minRSABits is the smallest RSA key size accepted.
+const minRSABits = 2048
+
+var (
+	// ErrInvalidKey is returned when a key can't be parsed.
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrUnsupportedKey is returned for keys of an unsupported type or size.
+	ErrUnsupportedKey = errors.New("unsupported key")
+)
+
+// Key is a key used to sign or verify JWS. Private keys can do both, and
+// public keys can only verify.
+type Key struct {
+	// ID is the key ID sent in the kid header. It defaults to the RFC 7638
+	// thumbprint of the key.
+	ID string
+	// Algorithm is the signing algorithm used with the key.
+	Algorithm string
+
+	public  crypto.PublicKey
+	private crypto.Signer
+}
+
+// NewKey creates a key from an Ed25519, ECDSA or RSA public or private key.
+// If id is empty, the thumbprint of the key is used.
+func NewKey(key any, id string) (*Key, error) {
+	k := &Key{ID: id}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		k.private = signer
+		key = signer.Public()
+	}
+
+	switch pub := key.(type) {
+	case ed25519.PublicKey:
+		k.Algorithm = EdDSA
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			k.Algorithm = ES256
+		case elliptic.P384():
+			k.Algorithm = ES384
+		case elliptic.P521():
+			k.Algorithm = ES512
+		default:
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrUnsupportedKey, pub.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("%w: RSA keys must have at least %d bits", ErrUnsupportedKey, minRSABits)
+		}
+
+		k.Algorithm = RS256
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrUnsupportedKey, key)
+	}
+
+	k.public = key
+
+	if k.ID == "" {
+		k.ID = k.Thumbprint()
+	}
+
+	return k, nil
+}
+
+// Private reports whether the key can sign.
+func (k *Key) Private() bool {
+	return k.private != nil
+}
+
+// Public returns the public part of the key.
+func (k *Key) Public() *Key {
+	return &Key{ID: k.ID, Algorithm: k.Algorithm, public: k.public}
+}
+
+// JWK returns the public key as a JWK.
+func (k *Key) JWK() JWK {
+	jwk := JWK{ID: k.ID, Algorithm: k.Algorithm, Use: "sig"}
+
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", encode(pub)
+	case *ecdsa.PublicKey:
+		// Only fails for keys that NewKey rejects
+		point, _ := pub.Bytes()
+		size := (len(point) - 1) / 2 //nolint:mnd // The point is 0x04 || X || Y
+
+		jwk.KeyType, jwk.Curve = "EC", pub.Curve.Params().Name
+		jwk.X, jwk.Y = encode(point[1:1+size]), encode(point[1+size:])
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N, jwk.E = encode(pub.N.Bytes()), encode(big.NewInt(int64(pub.E)).Bytes())
+	}
+
+	return jwk
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key.
+func (k *Key) Thumbprint() string {
+	jwk := k.JWK()
+
+	// The required members, which encoding/json sorts like RFC 7638 wants
+	members := map[string]string{"kty": jwk.KeyType}
+
+	switch jwk.KeyType {
+	case "OKP":
+		members["crv"], members["x"] = jwk.Curve, jwk.X
+	case "EC":
+		members["crv"], members["x"], members["y"] = jwk.Curve, jwk.X, jwk.Y
+	case "RSA":
+		members["n"], members["e"] = jwk.N, jwk.E
+	}
+
+	data, _ := json.Marshal(members) //nolint:errchkjson // Maps of strings always encode
+	sum := sha256.Sum256(data)
+
+	return encode(sum[:])
+}
+
+// JWK is a JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// EC and OKP keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Private keys
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+}
+
+// Key returns the key described by the JWK.
+func (j JWK) Key() (*Key, error) {
+	key, err := j.key()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	k, err := NewKey(key, j.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Algorithm != "" && j.Algorithm != k.Algorithm {
+		return nil, fmt.Errorf("%w: %s keys can't be used with %s", ErrUnsupportedKey, j.KeyType, j.Algorithm)
+	}
+
+	return k, nil
+}
+
+// key decodes the key in the JWK.
+func (j JWK) key() (any, error) {
+	switch j.KeyType {
+	case "OKP":
+		return j.okpKey()
+	case "EC":
+		return j.ecKey()
+	case "RSA":
+		return j.rsaKey()
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrUnsupportedKey, j.KeyType)
+	}
+}
+
+func (j JWK) okpKey() (any, error) {
+	if j.Curve != "Ed25519" {
+		return nil, fmt.Errorf("%w: unsupported curve %q", ErrUnsupportedKey, j.Curve)
+	}
+
+	x, err := decode("x", j.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("x must be an Ed25519 public key") //nolint:err113 // Wrapped by the caller
+	}
+
+	if j.D == "" {
+		return ed25519.PublicKey(x), nil
+	}
+
+	d, err := decode("d", j.D)
+	if err != nil || len(d) != ed25519.SeedSize {
+		return nil, errors.New("d must be an Ed25519 seed") //nolint:err113 // Wrapped by the caller
+	}
+
+	key := ed25519.NewKeyFromSeed(d)
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), x) { //nolint:forcetypeassert // Always an Ed25519 key
+		return nil, errors.New("x doesn't match d") //nolint:err113 // Wrapped by the caller
+	}
+
+	return key, nil
+}
+
+func (j JWK) ecKey() (any, error) {
+	var curve elliptic.Curve
+
+	switch j.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("%w: unsupported curve %q", ErrUnsupportedKey, j.Curve)
+	}
+
+	x, errX := decode("x", j.X)
+	y, errY := decode("y", j.Y)
+
+	if err := errors.Join(errX, errY); err != nil {
+		return nil, err
+	}
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	if j.D == "" {
+		return pub, nil
+	}
+
+	d, err := decode("d", j.D)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.ParseRawPrivateKey(curve, d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	if !key.PublicKey.Equal(pub) {
+		return nil, errors.New("x and y don't match d") //nolint:err113 // Wrapped by the caller
+	}
+
+	return key, nil
+}
+
+func (j JWK) rsaKey() (any, error) {
+	n, errN := decode("n", j.N)
+	e, errE := decode("e", j.E)
+
+	if err := errors.Join(errN, errE); err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("e is too large") //nolint:err113 // Wrapped by the caller
+	}
+
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+
+	if j.D == "" {
+		return pub, nil
+	}
+
+	// The CRT values are computed again by Precompute
+	d, errD := decode("d", j.D)
+	p, errP := decode("p", j.P)
+	q, errQ := decode("q", j.Q)
+
+	if err := errors.Join(errD, errP, errQ); err != nil {
+		return nil, err
+	}
+
+	key := &rsa.PrivateKey{
+		PublicKey: *pub,
+		D:         new(big.Int).SetBytes(d),
+		Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+	}
+
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	key.Precompute()
+
+	return key, nil
+}
+
+// KeySet is a JWK Set (RFC 7517), used to publish public keys.
+type KeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet returns the set of the public keys.
+func NewKeySet(keys ...*Key) KeySet {
+	set := KeySet{Keys: make([]JWK, 0, len(keys))}
+
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.JWK())
+	}
+
+	return set
+}
+
+// ParseKeys parses the keys in a PEM file, a JWK or a JWK Set. PEM files
+// can hold PKCS #8, PKCS #1 and SEC 1 private keys and PKIX and PKCS #1
+// public keys, and blocks of other types are skipped.
+func ParseKeys(data []byte) ([]*Key, error) {
+	data = bytes.TrimSpace(data)
+
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return parsePEM(data)
+	}
+
+	var doc struct {
+		JWK
+
+		Keys []JWK `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	jwks := doc.Keys
+	if doc.KeyType != "" {
+		jwks = []JWK{doc.JWK}
+	}
+
+	keys := make([]*Key, 0, len(jwks))
+
+	for _, jwk := range jwks {
+		k, err := jwk.Key()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no keys found", ErrInvalidKey)
+	}
+
+	return keys, nil
+}
+
+// parsePEM parses the keys in PEM data.
+func parsePEM(data []byte) ([]*Key, error) {
+	var keys []*Key
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var (
+			key any
+			err error
+		)
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+
+		k, err := NewKey(key, "")
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no keys found", ErrInvalidKey)
+	}
+
+	return keys, nil
+}
+
+// LoadKeys reads the keys in each file, in order. See ParseKeys for the
+// formats supported.
+func LoadKeys(paths ...string) ([]*Key, error) {
+	var keys []*Key
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key file: %w", err)
+		}
+
+		parsed, err := ParseKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+
+		keys = append(keys, parsed...)
+	}
+
+	return keys, nil
+}
+
+// encode encodes data as unpadded base64url.
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decode decodes a required unpadded base64url member.
+func decode(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s is required", name) //nolint:err113 // Wrapped by the caller
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not base64url: %w", name, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,306 @@
+package jws_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/jws"
+)
+
+// generateKeys returns a private key of each supported type.
+func generateKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return map[string]crypto.Signer{
+		jws.EdDSA: ed,
+		jws.ES256: p256,
+		jws.ES384: p384,
+		jws.ES512: p521,
+		jws.RS256: rsaKey,
+	}
+}
+
+func pemBlock(t *testing.T, blockType string, der []byte) []byte {
+	t.Helper()
+
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestNewKey(t *testing.T) {
+	t.Parallel()
+
+	for alg, signer := range generateKeys(t) {
+		k, err := jws.NewKey(signer, "")
+		require.NoError(t, err, alg)
+		require.Equal(t, alg, k.Algorithm)
+		require.True(t, k.Private())
+		require.Equal(t, k.Thumbprint(), k.ID)
+
+		// Public keys have the same thumbprint
+		pub, err := jws.NewKey(signer.Public(), "")
+		require.NoError(t, err, alg)
+		require.False(t, pub.Private())
+		require.Equal(t, k.ID, pub.ID)
+	}
+
+	t.Run("rejects unsupported keys", func(t *testing.T) {
+		t.Parallel()
+
+		small, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+
+		_, err = jws.NewKey(small, "")
+		require.ErrorIs(t, err, jws.ErrUnsupportedKey)
+
+		_, err = jws.NewKey("not a key", "")
+		require.ErrorIs(t, err, jws.ErrUnsupportedKey)
+	})
+}
+
+func TestKey_Thumbprint(t *testing.T) {
+	t.Parallel()
+
+	// The example from RFC 7638, section 3.1
+	jwk := jws.JWK{
+		KeyType: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n9" +
+			"1CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+
+	k, err := jwk.Key()
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", k.Thumbprint())
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	keys := generateKeys(t)
+	ed := keys[jws.EdDSA].(ed25519.PrivateKey)  //nolint:forcetypeassert // Tests
+	p256 := keys[jws.ES256].(*ecdsa.PrivateKey) //nolint:forcetypeassert // Tests
+	rsaKey := keys[jws.RS256].(*rsa.PrivateKey) //nolint:forcetypeassert // Tests
+	edKey, err := jws.NewKey(ed, "")
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ed)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(p256)
+	require.NoError(t, err)
+
+	pkix, err := x509.MarshalPKIXPublicKey(p256.Public())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantAlgs []string
+		private  bool
+		wantErr  error
+	}{
+		{
+			name:     "PKCS #8 private key",
+			data:     pemBlock(t, "PRIVATE KEY", pkcs8),
+			wantAlgs: []string{jws.EdDSA},
+			private:  true,
+		},
+		{
+			name:     "SEC 1 private key",
+			data:     pemBlock(t, "EC PRIVATE KEY", sec1),
+			wantAlgs: []string{jws.ES256},
+			private:  true,
+		},
+		{
+			name:     "PKCS #1 private key",
+			data:     pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+			wantAlgs: []string{jws.RS256},
+			private:  true,
+		},
+		{
+			name:     "PKIX public key",
+			data:     pemBlock(t, "PUBLIC KEY", pkix),
+			wantAlgs: []string{jws.ES256},
+		},
+		{
+			name: "several PEM blocks",
+			data: append(append(pemBlock(t, "CERTIFICATE", []byte("skipped")),
+				pemBlock(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))...),
+				pemBlock(t, "PUBLIC KEY", pkix)...),
+			wantAlgs: []string{jws.RS256, jws.ES256},
+		},
+		{
+			name: "JWK set",
+			data: func() []byte {
+				data, err := json.Marshal(jws.NewKeySet(edKey))
+				require.NoError(t, err)
+
+				return data
+			}(),
+			wantAlgs: []string{jws.EdDSA},
+		},
+		{
+			name:    "PEM without keys",
+			data:    pemBlock(t, "CERTIFICATE", []byte("skipped")),
+			wantErr: jws.ErrInvalidKey,
+		},
+		{
+			name:    "invalid PEM key",
+			data:    pemBlock(t, "PRIVATE KEY", []byte("invalid")),
+			wantErr: jws.ErrInvalidKey,
+		},
+		{
+			name:    "invalid JSON",
+			data:    []byte(`{"kty": `),
+			wantErr: jws.ErrInvalidKey,
+		},
+		{
+			name:    "empty JWK set",
+			data:    []byte(`{"keys": []}`),
+			wantErr: jws.ErrInvalidKey,
+		},
+		{
+			name:    "unsupported JWK",
+			data:    []byte(`{"kty": "oct", "k": "c2VjcmV0"}`),
+			wantErr: jws.ErrUnsupportedKey,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := jws.ParseKeys(tc.data)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, got, len(tc.wantAlgs))
+
+			for i, k := range got {
+				require.Equal(t, tc.wantAlgs[i], k.Algorithm)
+				require.Equal(t, tc.private, k.Private())
+			}
+		})
+	}
+}
+
+func TestJWK(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trips keys", func(t *testing.T) {
+		t.Parallel()
+
+		for alg, signer := range generateKeys(t) {
+			k, err := jws.NewKey(signer, "my-key")
+			require.NoError(t, err, alg)
+
+			jwk := k.JWK()
+			require.Equal(t, "my-key", jwk.ID)
+			require.Equal(t, alg, jwk.Algorithm)
+			require.Empty(t, jwk.D, "JWKs only have the public key")
+
+			got, err := jwk.Key()
+			require.NoError(t, err, alg)
+			require.Equal(t, jwk, got.JWK())
+		}
+	})
+
+	t.Run("parses private keys", func(t *testing.T) {
+		t.Parallel()
+
+		// The Ed25519 example from RFC 8037, appendix A.1
+		k, err := jws.JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			D:       "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+		}.Key()
+		require.NoError(t, err)
+		require.True(t, k.Private())
+		require.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", k.ID)
+
+		_, err = jws.JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			D:       "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+		}.Key()
+		require.ErrorIs(t, err, jws.ErrInvalidKey)
+	})
+
+	t.Run("rejects keys for other algorithms", func(t *testing.T) {
+		t.Parallel()
+
+		k, err := jws.NewKey(generateKeys(t)[jws.ES256], "")
+		require.NoError(t, err)
+
+		jwk := k.JWK()
+		jwk.Algorithm = jws.ES384
+
+		_, err = jwk.Key()
+		require.ErrorIs(t, err, jws.ErrUnsupportedKey)
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	k, err := jws.NewKey(generateKeys(t)[jws.EdDSA], "")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(k.JWK())
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "key.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	keys, err := jws.LoadKeys(path, path)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	_, err = jws.LoadKeys(filepath.Join(dir, "missing.pem"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalid, []byte("nothing"), 0o600))
+
+	_, err = jws.LoadKeys(invalid)
+	require.ErrorIs(t, err, jws.ErrInvalidKey)
+	require.ErrorContains(t, err, invalid)
+}
@@ -0,0 +1,136 @@
+package webfingers
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/idna"
+)
+
+// VirtualHost holds the webfingers served on a host.
+type VirtualHost struct {
+	// Fingers are the webfingers of the host.
+	Fingers WebFingers
+	// Domains are the domains of the acct: resources served on the host.
+	// Lookups of acct: resources in any other domain are rejected. If empty,
+	// only the host itself is served.
+	Domains []string
+}
+
+// VirtualHosts maps host names to their virtual hosts.
+type VirtualHosts map[string]*VirtualHost
+
+// Count returns the number of webfingers in every host, not counting aliases.
+func (h VirtualHosts) Count() int {
+	count := 0
+
+	for _, host := range h {
+		count += host.Fingers.Count()
+	}
+
+	return count
+}
+
+// HostStore holds virtual hosts that can be replaced while they are being read.
+type HostStore struct {
+	fallback string
+	current  atomic.Pointer[hostSnapshot]
+}
+
+// hostSnapshot is a set of virtual hosts and the stores used to look them up.
+type hostSnapshot struct {
+	hosts   VirtualHosts
+	entries map[string]*hostEntry
+}
+
+// hostEntry is what is used to look up resources on a host.
+type hostEntry struct {
+	store   *Store
+	domains []string
+}
+
+// NewHostStore creates a new store holding the given virtual hosts. Requests
+// to hosts that are not in the store are served by the fallback host, or not
+// at all if it is empty.
+func NewHostStore(hosts VirtualHosts, fallback string) *HostStore {
+	s := &HostStore{fallback: hostName(fallback)}
+	s.Store(hosts)
+
+	return s
+}
+
+// Load returns the current virtual hosts.
+func (s *HostStore) Load() VirtualHosts {
+	return s.current.Load().hosts
+}
+
+// Store replaces the current virtual hosts.
+func (s *HostStore) Store(hosts VirtualHosts) {
+	// Never hold a nil map so lookups are always safe
+	if hosts == nil {
+		hosts = make(VirtualHosts)
+	}
+
+	entries := make(map[string]*hostEntry, len(hosts))
+
+	for host, vhost := range hosts {
+		name := hostName(host)
+
+		entry := &hostEntry{store: NewStore(vhost.Fingers)}
+		for _, domain := range vhost.Domains {
+			entry.domains = append(entry.domains, hostName(domain))
+		}
+
+		if len(entry.domains) == 0 {
+			entry.domains = []string{name}
+		}
+
+		entries[name] = entry
+	}
+
+	s.current.Store(&hostSnapshot{
+		hosts:   hosts,
+		entries: entries,
+	})
+}
+
+// Lookup returns the webfinger of a normalized resource on the host, which may
+// have a port. acct: resources are only found on the hosts serving their domain.
+func (s *HostStore) Lookup(host, resource string) (*WebFinger, bool) {
+	current := s.current.Load()
+
+	entry, ok := current.entries[hostName(host)]
+	if !ok {
+		if entry, ok = current.entries[s.fallback]; !ok {
+			return nil, false
+		}
+	}
+
+	if userHost, ok := strings.CutPrefix(resource, "acct:"); ok {
+		domain := userHost[strings.LastIndex(userHost, "@")+1:]
+
+		if !slices.Contains(entry.domains, hostName(domain)) {
+			return nil, false
+		}
+	}
+
+	return entry.store.Lookup(resource)
+}
+
+// hostName returns the lowercase ASCII form of a host without its port.
+func hostName(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+
+	return host
+}
@@ -0,0 +1,124 @@
+package webfingers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func newHosts(t *testing.T) webfingers.VirtualHosts {
+	t.Helper()
+
+	example, err := webfingers.NewWebFingers(webfingers.Resources{
+		"alice@example.com":        {"name": "Alice"},
+		"bob@example.org":          {"name": "Bob"},
+		"https://example.com/page": {"name": "Page"},
+	}, nil)
+	require.NoError(t, err)
+
+	other, err := webfingers.NewWebFingers(webfingers.Resources{
+		"carol@other.net": {"name": "Carol"},
+		"dave@other.org":  {"name": "Dave"},
+	}, nil)
+	require.NoError(t, err)
+
+	return webfingers.VirtualHosts{
+		"example.com": {Fingers: example},
+		"other.net":   {Fingers: other, Domains: []string{"other.net", "other.org"}},
+	}
+}
+
+func TestHostStore_Lookup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		fallback string
+		host     string
+		resource string
+		want     string
+	}{
+		{
+			name:     "finds resources on their host",
+			host:     "example.com",
+			resource: "acct:alice@example.com",
+			want:     "Alice",
+		},
+		{
+			name:     "ignores the port and case of the host",
+			host:     "Example.COM:8080",
+			resource: "acct:alice@example.com",
+			want:     "Alice",
+		},
+		{
+			name:     "does not find resources of other hosts",
+			host:     "example.com",
+			resource: "acct:carol@other.net",
+		},
+		{
+			name:     "rejects acct: resources in domains the host doesn't serve",
+			host:     "example.com",
+			resource: "acct:bob@example.org",
+		},
+		{
+			name:     "serves the configured domains",
+			host:     "other.net",
+			resource: "acct:dave@other.org",
+			want:     "Dave",
+		},
+		{
+			name:     "serves other URIs in any domain",
+			host:     "example.com",
+			resource: "https://example.com/page",
+			want:     "Page",
+		},
+		{
+			name:     "does not serve unknown hosts",
+			host:     "unknown.com",
+			resource: "acct:alice@example.com",
+		},
+		{
+			name:     "serves unknown hosts with the fallback",
+			fallback: "example.com",
+			host:     "unknown.com",
+			resource: "acct:alice@example.com",
+			want:     "Alice",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := webfingers.NewHostStore(newHosts(t), tc.fallback)
+
+			got, ok := store.Lookup(tc.host, tc.resource)
+			require.Equal(t, tc.want != "", ok)
+
+			if ok {
+				require.Equal(t, tc.want, got.Properties["name"])
+			}
+		})
+	}
+}
+
+func TestHostStore_Store(t *testing.T) {
+	t.Parallel()
+
+	store := webfingers.NewHostStore(nil, "")
+
+	_, ok := store.Lookup("example.com", "acct:alice@example.com")
+	require.False(t, ok)
+
+	hosts := newHosts(t)
+	store.Store(hosts)
+
+	_, ok = store.Lookup("example.com", "acct:alice@example.com")
+	require.True(t, ok)
+	require.Equal(t, hosts, store.Load())
+	require.Equal(t, 5, hosts.Count())
+}
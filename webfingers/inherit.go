@@ -0,0 +1,170 @@
+package webfingers
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// DefaultsResource is the resource whose fields every other resource
+	// inherits. It is not served.
+	DefaultsResource = "defaults"
+	// TemplatePrefix starts the names of templates, which are resources that
+	// are not served but whose fields other resources can inherit.
+	TemplatePrefix = "."
+	// ExtendsField is the resource field that holds the templates a resource
+	// inherits from, separated by whitespace. Later templates override the
+	// fields of earlier ones.
+	ExtendsField = "extends"
+	// UnsetField is the resource field that holds the inherited fields a
+	// resource removes, separated by whitespace.
+	UnsetField = "unset"
+)
+
+var (
+	// ErrUnknownTemplate is returned when a resource extends a template that does not exist.
+	ErrUnknownTemplate = errors.New("unknown template")
+	// ErrCyclicInheritance is returned when a template ends up extending itself.
+	ErrCyclicInheritance = errors.New("cyclic inheritance")
+)
+
+// IsTemplate reports whether the resource is the defaults or a template,
+// which are only inherited and never served.
+func IsTemplate(resource string) bool {
+	return resource == DefaultsResource || strings.HasPrefix(resource, TemplatePrefix)
+}
+
+// Inherit returns the resources with the fields they inherit from the
+// defaults and their templates, without the defaults and templates
+// themselves. Fields are inherited from the defaults first and then from each
+// template in the order they are extended, the fields in unset are removed,
+// and the fields of the resource itself override them all. Templates can
+// extend other templates, but not themselves.
+//
+// text returns the string in a value, and is used to read the extends and
+// unset fields.
+func Inherit[V any](resources map[string]map[string]V, text func(V) string) (map[string]map[string]V, error) {
+	h := &inheritance[V]{
+		resources: resources,
+		text:      text,
+		layers:    make(map[string]*layer[V]),
+	}
+
+	// Resolve every template, even unused ones, so mistakes in them are caught
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		if _, err := h.layer(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	base := newLayer[V]()
+	if defaults, ok := h.layers[DefaultsResource]; ok {
+		base.apply(defaults)
+	}
+
+	inherited := make(map[string]map[string]V, len(resources))
+
+	for name := range resources {
+		if IsTemplate(name) {
+			continue
+		}
+
+		resolved := newLayer[V]()
+		resolved.apply(base)
+		resolved.apply(h.layers[name])
+
+		inherited[name] = resolved.fields
+	}
+
+	return inherited, nil
+}
+
+// layer holds the fields a resource sets and removes on top of the ones it
+// inherits.
+type layer[V any] struct {
+	fields map[string]V
+	unset  map[string]bool
+}
+
+func newLayer[V any]() *layer[V] {
+	return &layer[V]{
+		fields: make(map[string]V),
+		unset:  make(map[string]bool),
+	}
+}
+
+// apply stacks other on top of the layer.
+func (l *layer[V]) apply(other *layer[V]) {
+	for field := range other.unset {
+		delete(l.fields, field)
+		l.unset[field] = true
+	}
+
+	for field, value := range other.fields {
+		l.fields[field] = value
+		delete(l.unset, field)
+	}
+}
+
+// inheritance resolves the layers of resources.
+type inheritance[V any] struct {
+	resources map[string]map[string]V
+	text      func(V) string
+
+	// layers holds the layers resolved so far, including the templates they
+	// extend.
+	layers map[string]*layer[V]
+}
+
+// layer returns the layer of a resource. chain holds the templates being
+// resolved that lead to it, to detect cycles.
+func (h *inheritance[V]) layer(name string, chain []string) (*layer[V], error) {
+	if l, ok := h.layers[name]; ok {
+		return l, nil
+	}
+
+	if i := slices.Index(chain, name); i >= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCyclicInheritance, strings.Join(append(chain[i:], name), " extends "))
+	}
+
+	chain = append(chain, name)
+	fields := h.resources[name]
+	l := newLayer[V]()
+
+	if extends, ok := fields[ExtendsField]; ok {
+		for _, template := range strings.Fields(h.text(extends)) {
+			if _, ok := h.resources[template]; !ok || !strings.HasPrefix(template, TemplatePrefix) {
+				return nil, fmt.Errorf("%w: %s extends %s", ErrUnknownTemplate, name, template)
+			}
+
+			parent, err := h.layer(template, chain)
+			if err != nil {
+				return nil, err
+			}
+
+			l.apply(parent)
+		}
+	}
+
+	own := newLayer[V]()
+
+	if unset, ok := fields[UnsetField]; ok {
+		for _, field := range strings.Fields(h.text(unset)) {
+			own.unset[field] = true
+		}
+	}
+
+	for field, value := range fields {
+		if field != ExtendsField && field != UnsetField {
+			own.fields[field] = value
+		}
+	}
+
+	l.apply(own)
+	h.layers[name] = l
+
+	return l, nil
+}
@@ -0,0 +1,159 @@
+package webfingers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestInherit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		resources webfingers.Resources
+		want      webfingers.Resources
+		wantErr   error
+	}{
+		{
+			name: "keeps resources without inheritance",
+			resources: webfingers.Resources{
+				"user@example.com": {"name": "John Doe"},
+			},
+			want: webfingers.Resources{
+				"user@example.com": {"name": "John Doe"},
+			},
+		},
+		{
+			name: "inherits the defaults",
+			resources: webfingers.Resources{
+				"defaults":          {"organization": "Example", "issuer": "https://auth.example.com"},
+				"alice@example.com": {"name": "Alice"},
+				"bob@example.com":   {"name": "Bob", "organization": "Other"},
+			},
+			want: webfingers.Resources{
+				"alice@example.com": {"name": "Alice", "organization": "Example", "issuer": "https://auth.example.com"},
+				"bob@example.com":   {"name": "Bob", "organization": "Other", "issuer": "https://auth.example.com"},
+			},
+		},
+		{
+			name: "inherits templates in order",
+			resources: webfingers.Resources{
+				"defaults":          {"organization": "Example"},
+				".staff":            {"role": "staff", "team": "none"},
+				".engineering":      {"extends": ".staff", "team": "engineering"},
+				".oncall":           {"role": "oncall"},
+				"alice@example.com": {"extends": ".engineering .oncall", "name": "Alice"},
+			},
+			want: webfingers.Resources{
+				"alice@example.com": {"organization": "Example", "role": "oncall", "team": "engineering", "name": "Alice"},
+			},
+		},
+		{
+			name: "removes unset fields",
+			resources: webfingers.Resources{
+				"defaults":          {"organization": "Example", "issuer": "https://auth.example.com"},
+				".contractor":       {"unset": "organization"},
+				".staff":            {"role": "staff"},
+				"alice@example.com": {"extends": ".contractor .staff", "name": "Alice"},
+				"bob@example.com":   {"unset": "issuer organization", "organization": "Other"},
+			},
+			want: webfingers.Resources{
+				"alice@example.com": {"issuer": "https://auth.example.com", "role": "staff", "name": "Alice"},
+				"bob@example.com":   {"organization": "Other"},
+			},
+		},
+		{
+			name: "lets the defaults extend templates",
+			resources: webfingers.Resources{
+				".base":            {"organization": "Example"},
+				"defaults":         {"extends": ".base"},
+				"user@example.com": {},
+			},
+			want: webfingers.Resources{
+				"user@example.com": {"organization": "Example"},
+			},
+		},
+		{
+			name: "rejects unknown templates",
+			resources: webfingers.Resources{
+				"user@example.com": {"extends": ".missing"},
+			},
+			wantErr: webfingers.ErrUnknownTemplate,
+		},
+		{
+			name: "rejects extending resources",
+			resources: webfingers.Resources{
+				"alice@example.com": {"name": "Alice"},
+				"user@example.com":  {"extends": "alice@example.com"},
+			},
+			wantErr: webfingers.ErrUnknownTemplate,
+		},
+		{
+			name: "rejects cycles",
+			resources: webfingers.Resources{
+				".a":               {"extends": ".b"},
+				".b":               {"extends": ".c"},
+				".c":               {"extends": ".a"},
+				"user@example.com": {"name": "John Doe"},
+			},
+			wantErr: webfingers.ErrCyclicInheritance,
+		},
+		{
+			name: "rejects templates extending themselves",
+			resources: webfingers.Resources{
+				".a": {"extends": ".a"},
+			},
+			wantErr: webfingers.ErrCyclicInheritance,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := webfingers.Inherit(tc.resources, func(value string) string { return value })
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, webfingers.Resources(got))
+		})
+	}
+}
+
+func TestNewWebFingers_Inheritance(t *testing.T) {
+	t.Parallel()
+
+	fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+		"defaults":          {"issuer": "https://auth.example.com"},
+		".engineering":      {"organization": "Engineering"},
+		"alice@example.com": {"extends": ".engineering", "name": "Alice"},
+	}, webfingers.URNAliases{"issuer": "http://openid.net/specs/connect/1.0/issuer"})
+	require.NoError(t, err)
+
+	// Templates are not served
+	require.Len(t, fingers, 1)
+	require.Equal(t, &webfingers.WebFinger{
+		Subject: "acct:alice@example.com",
+		Links: []webfingers.Link{
+			{Rel: "http://openid.net/specs/connect/1.0/issuer", Href: "https://auth.example.com"},
+		},
+		Properties: map[string]string{"name": "Alice", "organization": "Engineering"},
+	}, fingers["acct:alice@example.com"])
+
+	_, err = webfingers.NewWebFingers(webfingers.Resources{
+		".a":               {"extends": ".b"},
+		".b":               {"extends": ".a"},
+		"user@example.com": {"extends": ".a"},
+	}, nil)
+	require.ErrorIs(t, err, webfingers.ErrCyclicInheritance)
+	require.ErrorContains(t, err, ".a extends .b extends .a")
+}
@@ -0,0 +1,157 @@
+package webfingers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidResource is returned when a resource can't be normalized.
+var ErrInvalidResource = errors.New("invalid resource")
+
+// DefaultNormalizer is the normalizer used when none is given.
+var DefaultNormalizer = Normalizer{} //nolint:gochecknoglobals // Read-only default
+
+// Normalizer turns resources into their canonical form, so that different
+// ways of writing the same resource match the same webfinger.
+//
+// Resources without a scheme are treated as acct: URIs, percent-encoded acct:
+// URIs are decoded and hosts are lowercased and converted to their ASCII
+// (punycode) form.
+type Normalizer struct {
+	// IgnoreLocalCase lowercases the local part of acct: URIs, so that
+	// "acct:Me@example.com" and "acct:me@example.com" are the same resource.
+	IgnoreLocalCase bool
+}
+
+// Normalize returns the canonical form of resource using the default normalizer.
+func Normalize(resource string) (string, error) {
+	return DefaultNormalizer.Normalize(resource)
+}
+
+// Normalize returns the canonical form of resource.
+func (n Normalizer) Normalize(resource string) (string, error) {
+	resource = strings.TrimSpace(resource)
+
+	// Resources without a scheme are acct: URIs, like user@example.com or
+	// @user@example.com as written in the fediverse. They are checked before
+	// parsing since url.Parse rejects ports in them, as in user@localhost:8080.
+	if !hasScheme(resource) {
+		return n.normalizeAcct(strings.TrimPrefix(resource, "@"))
+	}
+
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", fmt.Errorf("%w (%s): %w", ErrInvalidResource, resource, err)
+	}
+
+	switch {
+	case u.Scheme == "acct":
+		return n.normalizeAcct(u.Opaque)
+	// Other opaque URIs, like mailto: or urn:, have no host to normalize.
+	case u.Opaque != "":
+		return u.Scheme + ":" + u.Opaque, nil
+	case u.Host == "":
+		return "", fmt.Errorf("%w (%s): missing host", ErrInvalidResource, resource)
+	}
+
+	u.Host, err = normalizeHost(u.Host)
+	if err != nil {
+		return "", fmt.Errorf("%w (%s): %w", ErrInvalidResource, resource, err)
+	}
+
+	return u.String(), nil
+}
+
+// hasScheme reports whether the resource starts with a URI scheme (RFC 3986, section 3.1).
+func hasScheme(resource string) bool {
+	scheme, _, ok := strings.Cut(resource, ":")
+	if !ok || scheme == "" {
+		return false
+	}
+
+	for i, c := range scheme {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeAcct normalizes the user@host part of an acct: URI.
+func (n Normalizer) normalizeAcct(userHost string) (string, error) {
+	decoded, err := url.PathUnescape(userHost)
+	if err != nil {
+		return "", fmt.Errorf("%w (acct:%s): %w", ErrInvalidResource, userHost, err)
+	}
+
+	// The local part may contain @, but the host can't
+	i := strings.LastIndex(decoded, "@")
+	if i <= 0 || i == len(decoded)-1 {
+		return "", fmt.Errorf("%w (acct:%s): must be user@host", ErrInvalidResource, userHost)
+	}
+
+	local, host := decoded[:i], decoded[i+1:]
+
+	host, err = normalizeHost(host)
+	if err != nil {
+		return "", fmt.Errorf("%w (acct:%s): %w", ErrInvalidResource, userHost, err)
+	}
+
+	if n.IgnoreLocalCase {
+		local = strings.ToLower(local)
+	}
+
+	return "acct:" + local + "@" + host, nil
+}
+
+// normalizeHost lowercases the host and converts it to ASCII, keeping the port if any.
+func normalizeHost(hostport string) (string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, ""
+	}
+
+	// IP addresses are kept as they are
+	if net.ParseIP(strings.Trim(host, "[]")) == nil {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("error converting host to ASCII: %w", err)
+		}
+	}
+
+	if port != "" {
+		return net.JoinHostPort(host, port), nil
+	}
+
+	return host, nil
+}
+
+// Normalized returns a copy of the map with its keys normalized by n. It
+// returns an error if two keys become the same.
+func (f WebFingers) Normalized(n Normalizer) (WebFingers, error) {
+	normalized := make(WebFingers, len(f))
+
+	for resource, finger := range f {
+		key, err := n.normalizeKey(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := normalized[key]; ok && other != finger {
+			return nil, fmt.Errorf("%w: %s and %s", ErrDuplicateResource, other.Subject, finger.Subject)
+		}
+
+		normalized[key] = finger
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,169 @@
+package webfingers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		normalizer webfingers.Normalizer
+		resource   string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:     "keeps canonical acct URIs",
+			resource: "acct:user@example.com",
+			want:     "acct:user@example.com",
+		},
+		{
+			name:     "adds the acct scheme",
+			resource: "user@example.com",
+			want:     "acct:user@example.com",
+		},
+		{
+			name:     "adds the acct scheme to hosts with ports",
+			resource: "user@localhost:8080",
+			want:     "acct:user@localhost:8080",
+		},
+		{
+			name:     "removes a leading @",
+			resource: "@user@example.com",
+			want:     "acct:user@example.com",
+		},
+		{
+			name:     "lowercases the scheme and host",
+			resource: "ACCT:User@Example.COM",
+			want:     "acct:User@example.com",
+		},
+		{
+			name:       "lowercases the local part if asked",
+			normalizer: webfingers.Normalizer{IgnoreLocalCase: true},
+			resource:   "acct:User@Example.COM",
+			want:       "acct:user@example.com",
+		},
+		{
+			name:     "decodes percent-encoded acct URIs",
+			resource: "acct:first.last%40company%40example.com",
+			want:     "acct:first.last@company@example.com",
+		},
+		{
+			name:     "converts unicode hosts to punycode",
+			resource: "acct:user@Bücher.example",
+			want:     "acct:user@xn--bcher-kva.example",
+		},
+		{
+			name:     "keeps punycode hosts",
+			resource: "acct:user@xn--bcher-kva.example",
+			want:     "acct:user@xn--bcher-kva.example",
+		},
+		{
+			name:     "trims spaces",
+			resource: " acct:user@example.com ",
+			want:     "acct:user@example.com",
+		},
+		{
+			name:     "normalizes URL hosts",
+			resource: "HTTPS://Bücher.Example:8443/Users/Alice",
+			want:     "https://xn--bcher-kva.example:8443/Users/Alice",
+		},
+		{
+			name:     "keeps IP addresses",
+			resource: "https://[::1]:8080/user",
+			want:     "https://[::1]:8080/user",
+		},
+		{
+			name:     "keeps other opaque URIs",
+			resource: "mailto:User@example.com",
+			want:     "mailto:User@example.com",
+		},
+		{
+			name:     "errors on acct URIs without a host",
+			resource: "acct:user@",
+			wantErr:  true,
+		},
+		{
+			name:     "errors on acct URIs without a user",
+			resource: "acct:@example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "errors on plain strings",
+			resource: "invalid",
+			wantErr:  true,
+		},
+		{
+			name:     "errors on URLs without a host",
+			resource: "https:///user",
+			wantErr:  true,
+		},
+		{
+			name:     "errors on invalid hosts",
+			resource: "acct:user@exa mple.com",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.normalizer.Normalize(tc.resource)
+			if tc.wantErr {
+				require.ErrorIs(t, err, webfingers.ErrInvalidResource)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWebFingers_Normalized(t *testing.T) {
+	t.Parallel()
+
+	n := webfingers.Normalizer{IgnoreLocalCase: true}
+
+	t.Run("normalizes every key", func(t *testing.T) {
+		t.Parallel()
+
+		fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+			"User@example.com": {
+				"aliases": "Alias@example.com",
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		got, err := fingers.Normalized(n)
+		require.NoError(t, err)
+
+		require.Len(t, got, 2)
+		require.Same(t, fingers["acct:User@example.com"], got["acct:user@example.com"])
+		require.Same(t, fingers["acct:User@example.com"], got["acct:alias@example.com"])
+		require.Equal(t, 1, got.Count())
+	})
+
+	t.Run("errors on collisions", func(t *testing.T) {
+		t.Parallel()
+
+		fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+			"User@example.com": {},
+			"user@example.com": {},
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = fingers.Normalized(n)
+		require.ErrorIs(t, err, webfingers.ErrDuplicateResource)
+	})
+}
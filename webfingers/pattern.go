@@ -0,0 +1,272 @@
+package webfingers
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// UserPlaceholder is replaced by the user part of the requested resource
+	// in patterns, and in the links and properties of their webfingers.
+	UserPlaceholder = "{user}"
+	// DomainPlaceholder is replaced by the domain of the requested resource
+	// in the links and properties of pattern webfingers.
+	DomainPlaceholder = "{domain}"
+
+	// wildcardPrefix is short for an acct: pattern matching any user, as in
+	// "*@example.com".
+	wildcardPrefix = "*@"
+	// placeholderToken stands in for the user placeholder while a pattern is
+	// normalized, since the placeholder itself is not valid in every URI.
+	placeholderToken = "finger-user-placeholder"
+)
+
+// IsPattern reports whether the resource is a pattern matching many resources,
+// like "*@example.com" or "https://example.com/@{user}".
+func IsPattern(resource string) bool {
+	resource = strings.TrimPrefix(strings.TrimSpace(resource), "acct:")
+
+	return strings.Contains(resource, UserPlaceholder) || strings.HasPrefix(resource, wildcardPrefix)
+}
+
+// normalizeKey normalizes a resource or pattern.
+func (n Normalizer) normalizeKey(resource string) (string, error) {
+	if !IsPattern(resource) {
+		return n.Normalize(resource)
+	}
+
+	pattern := strings.TrimSpace(resource)
+
+	// Expand the wildcard shorthand
+	if rest, ok := strings.CutPrefix(strings.TrimPrefix(pattern, "acct:"), wildcardPrefix); ok {
+		pattern = "acct:" + UserPlaceholder + "@" + rest
+	}
+
+	normalized, err := n.Normalize(strings.ReplaceAll(pattern, UserPlaceholder, placeholderToken))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(normalized, placeholderToken, UserPlaceholder), nil
+}
+
+// withoutPlaceholders replaces the placeholders in value with sample values,
+// so it can be validated.
+func withoutPlaceholders(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, UserPlaceholder, "user"), DomainPlaceholder, "example.com")
+}
+
+// resourceHost returns the host of a normalized resource.
+func resourceHost(resource string) string {
+	if userHost, ok := strings.CutPrefix(resource, "acct:"); ok {
+		return userHost[strings.LastIndex(userHost, "@")+1:]
+	}
+
+	// Parsed by hand, since url.Parse rejects placeholders in the host
+	_, rest, ok := strings.Cut(resource, "://")
+	if !ok {
+		return ""
+	}
+
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	// Drop any userinfo
+	return rest[strings.LastIndex(rest, "@")+1:]
+}
+
+// pattern is a compiled pattern key of a webfinger map.
+type pattern struct {
+	key    string
+	prefix string
+	suffix string
+	re     *regexp.Regexp
+	finger *WebFinger
+}
+
+// compilePattern compiles a normalized pattern key.
+func compilePattern(key string, finger *WebFinger) (*pattern, error) {
+	if strings.Count(key, UserPlaceholder) != 1 {
+		return nil, fmt.Errorf("%w (%s): patterns must have exactly one %s", ErrInvalidResource, key, UserPlaceholder)
+	}
+
+	if strings.Contains(resourceHost(key), UserPlaceholder) {
+		return nil, fmt.Errorf("%w (%s): patterns can't have placeholders in the host", ErrInvalidResource, key)
+	}
+
+	// Users can't span path segments, hold another domain or look like
+	// placeholders once expanded
+	userExpr := `([^/?#@{]+)`
+	if strings.HasPrefix(key, "acct:") {
+		userExpr = `([^/@{]+)`
+	}
+
+	expr := strings.Replace(regexp.QuoteMeta(key), regexp.QuoteMeta(UserPlaceholder), userExpr, 1)
+	prefix, suffix, _ := strings.Cut(key, UserPlaceholder)
+
+	return &pattern{
+		key:    key,
+		prefix: prefix,
+		suffix: suffix,
+		re:     regexp.MustCompile("^" + expr + "$"),
+		finger: finger,
+	}, nil
+}
+
+// comparePatterns orders longer, more specific patterns first.
+func comparePatterns(a, b *pattern) int {
+	return cmp.Or(cmp.Compare(len(b.key), len(a.key)), cmp.Compare(a.key, b.key))
+}
+
+// hostPatterns holds the patterns of a host, grouped by the literal prefix
+// before their placeholder, so a lookup only tries the patterns whose prefix
+// the resource starts with.
+type hostPatterns struct {
+	byPrefix map[string][]*pattern
+	// prefixLens holds the distinct lengths of the prefixes.
+	prefixLens []int
+}
+
+// patternIndex holds the patterns of a webfinger map, grouped by host.
+type patternIndex map[string]*hostPatterns
+
+// newPatternIndex compiles the patterns in fingers. Invalid patterns are skipped.
+func newPatternIndex(fingers WebFingers) patternIndex {
+	index := make(patternIndex)
+
+	for key, finger := range fingers {
+		if !strings.Contains(key, UserPlaceholder) {
+			continue
+		}
+
+		p, err := compilePattern(key, finger)
+		if err != nil {
+			continue
+		}
+
+		host := resourceHost(key)
+		if index[host] == nil {
+			index[host] = &hostPatterns{byPrefix: make(map[string][]*pattern)}
+		}
+
+		hp := index[host]
+		if _, ok := hp.byPrefix[p.prefix]; !ok {
+			hp.prefixLens = append(hp.prefixLens, len(p.prefix))
+		}
+
+		hp.byPrefix[p.prefix] = append(hp.byPrefix[p.prefix], p)
+	}
+
+	for _, hp := range index {
+		slices.Sort(hp.prefixLens)
+		hp.prefixLens = slices.Compact(hp.prefixLens)
+
+		for _, patterns := range hp.byPrefix {
+			slices.SortFunc(patterns, comparePatterns)
+		}
+	}
+
+	return index
+}
+
+// match returns the webfinger of the most specific pattern matching the
+// normalized resource.
+func (idx patternIndex) match(resource string) (*WebFinger, bool) {
+	host := resourceHost(resource)
+
+	hp, ok := idx[host]
+	if !ok {
+		return nil, false
+	}
+
+	var (
+		best *pattern
+		user string
+	)
+
+	for _, n := range hp.prefixLens {
+		if n > len(resource) {
+			break
+		}
+
+		for _, p := range hp.byPrefix[resource[:n]] {
+			// Patterns are sorted, so the rest of them are less specific
+			if best != nil && comparePatterns(p, best) > 0 {
+				break
+			}
+
+			if !strings.HasSuffix(resource, p.suffix) {
+				continue
+			}
+
+			if m := p.re.FindStringSubmatch(resource); m != nil {
+				best, user = p, m[1]
+
+				break
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, false
+	}
+
+	return best.finger.expand(user, host), true
+}
+
+// expand returns a copy of a pattern webfinger with its placeholders replaced.
+func (w *WebFinger) expand(user, domain string) *WebFinger {
+	// Replaced in a single pass, so nothing inserted is replaced again
+	replacer := strings.NewReplacer(UserPlaceholder, user, DomainPlaceholder, domain)
+	// Users are escaped in aliases and links, which are URIs
+	uriReplacer := strings.NewReplacer(UserPlaceholder, url.PathEscape(user), DomainPlaceholder, domain)
+
+	replace := func(s string, r *strings.Replacer) string {
+		// Most values have no placeholders
+		if !strings.Contains(s, "{") {
+			return s
+		}
+
+		return r.Replace(s)
+	}
+
+	expandMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+
+		expanded := make(map[string]string, len(m))
+		for k, v := range m {
+			expanded[k] = replace(v, replacer)
+		}
+
+		return expanded
+	}
+
+	expanded := &WebFinger{
+		Subject:    replace(w.Subject, replacer),
+		Properties: expandMap(w.Properties),
+		MaxAge:     w.MaxAge,
+		ModTime:    w.ModTime,
+	}
+
+	for _, alias := range w.Aliases {
+		expanded.Aliases = append(expanded.Aliases, replace(alias, uriReplacer))
+	}
+
+	for _, link := range w.Links {
+		link.Href = replace(link.Href, uriReplacer)
+		link.Template = replace(link.Template, uriReplacer)
+		link.Titles = expandMap(link.Titles)
+		link.Properties = expandMap(link.Properties)
+
+		expanded.Links = append(expanded.Links, link)
+	}
+
+	return expanded
+}
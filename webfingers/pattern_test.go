@@ -0,0 +1,223 @@
+package webfingers_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestIsPattern(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, webfingers.IsPattern("*@example.com"))
+	require.True(t, webfingers.IsPattern("acct:*@example.com"))
+	require.True(t, webfingers.IsPattern("acct:{user}@example.com"))
+	require.True(t, webfingers.IsPattern("https://example.com/@{user}"))
+	require.False(t, webfingers.IsPattern("acct:user@example.com"))
+	require.False(t, webfingers.IsPattern("https://example.com/*"))
+}
+
+func TestStore_Lookup(t *testing.T) {
+	t.Parallel()
+
+	fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+		"*@example.com": {
+			"aliases":  "https://example.com/@{user}",
+			"profile":  "https://example.com/users/{user}",
+			"issuer":   "https://sso.{domain}/",
+			"name":     "{user} at {domain}",
+			"homepage": "https://{user}.example.com",
+		},
+		"acct:team-{user}@example.com": {
+			"name": "Team {user}",
+		},
+		"acct:{user}-admins@example.com": {
+			"name": "Admins of {user}",
+		},
+		"alice@example.com": {
+			"name": "Alice",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	store := webfingers.NewStore(fingers)
+
+	tests := []struct {
+		name     string
+		resource string
+		want     *webfingers.WebFinger
+	}{
+		{
+			name:     "exact resources beat patterns",
+			resource: "acct:alice@example.com",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:alice@example.com",
+				Properties: map[string]string{"name": "Alice"},
+			},
+		},
+		{
+			name:     "expands wildcard patterns",
+			resource: "acct:bob@example.com",
+			want: &webfingers.WebFinger{
+				Subject: "acct:bob@example.com",
+				Aliases: []string{"https://example.com/@bob"},
+				Links: []webfingers.Link{
+					{Rel: "homepage", Href: "https://bob.example.com"},
+					{Rel: "issuer", Href: "https://sso.example.com/"},
+					{Rel: "profile", Href: "https://example.com/users/bob"},
+				},
+				Properties: map[string]string{"name": "bob at example.com"},
+			},
+		},
+		{
+			name:     "matches alias patterns",
+			resource: "https://example.com/@bob",
+			want: &webfingers.WebFinger{
+				Subject: "acct:bob@example.com",
+				Aliases: []string{"https://example.com/@bob"},
+				Links: []webfingers.Link{
+					{Rel: "homepage", Href: "https://bob.example.com"},
+					{Rel: "issuer", Href: "https://sso.example.com/"},
+					{Rel: "profile", Href: "https://example.com/users/bob"},
+				},
+				Properties: map[string]string{"name": "bob at example.com"},
+			},
+		},
+		{
+			name:     "prefers more specific patterns",
+			resource: "acct:team-red@example.com",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:team-red@example.com",
+				Properties: map[string]string{"name": "Team red"},
+			},
+		},
+		{
+			name:     "prefers more specific patterns with shorter prefixes",
+			resource: "acct:team-red-admins@example.com",
+			want: &webfingers.WebFinger{
+				Subject:    "acct:team-red-admins@example.com",
+				Properties: map[string]string{"name": "Admins of team-red"},
+			},
+		},
+		{
+			name:     "escapes users in aliases and hrefs",
+			resource: "acct:a?b@example.com",
+			want: &webfingers.WebFinger{
+				Subject: "acct:a?b@example.com",
+				Aliases: []string{"https://example.com/@a%3Fb"},
+				Links: []webfingers.Link{
+					{Rel: "homepage", Href: "https://a%3Fb.example.com"},
+					{Rel: "issuer", Href: "https://sso.example.com/"},
+					{Rel: "profile", Href: "https://example.com/users/a%3Fb"},
+				},
+				Properties: map[string]string{"name": "a?b at example.com"},
+			},
+		},
+		{
+			name:     "doesn't match other domains",
+			resource: "acct:bob@example.org",
+		},
+		{
+			name:     "doesn't match partial paths",
+			resource: "https://example.com/@bob/posts",
+		},
+		{
+			name:     "doesn't match users with slashes",
+			resource: "acct:a/b@example.com",
+		},
+		{
+			name:     "doesn't match users with other domains",
+			resource: "acct:evil@other.org@example.com",
+		},
+		{
+			name:     "doesn't match users with placeholders",
+			resource: "acct:{domain}@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := store.Lookup(tc.resource)
+			require.Equal(t, tc.want != nil, ok)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNewWebFingers_Patterns(t *testing.T) {
+	t.Parallel()
+
+	t.Run("normalizes patterns", func(t *testing.T) {
+		t.Parallel()
+
+		fingers, err := webfingers.NewWebFingers(webfingers.Resources{
+			"*@Example.COM": {},
+		}, nil)
+		require.NoError(t, err)
+
+		require.Contains(t, fingers, "acct:{user}@example.com")
+	})
+
+	t.Run("errors on multiple placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := webfingers.NewWebFingers(webfingers.Resources{
+			"https://example.com/{user}/{user}": {},
+		}, nil)
+		require.ErrorIs(t, err, webfingers.ErrInvalidResource)
+	})
+
+	t.Run("errors on placeholders in the host", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := webfingers.NewWebFingers(webfingers.Resources{
+			"https://{user}.example.com/": {},
+		}, nil)
+		require.ErrorIs(t, err, webfingers.ErrInvalidResource)
+	})
+}
+
+func BenchmarkStore_Lookup(b *testing.B) {
+	resources := webfingers.Resources{}
+
+	for i := range 10000 {
+		resources[fmt.Sprintf("user%d@example%d.com", i, i%100)] = map[string]string{
+			"name": "User",
+		}
+	}
+
+	for i := range 100 {
+		resources[fmt.Sprintf("*@example%d.com", i)] = map[string]string{
+			"profile": "https://example.com/{user}",
+		}
+		resources[fmt.Sprintf("https://example%d.com/@{user}", i)] = map[string]string{
+			"name": "{user}",
+		}
+	}
+
+	fingers, err := webfingers.NewWebFingers(resources, nil)
+	require.NoError(b, err)
+
+	store := webfingers.NewStore(fingers)
+
+	b.Run("exact", func(b *testing.B) {
+		for b.Loop() {
+			_, ok := store.Lookup("acct:user5000@example0.com")
+			require.True(b, ok)
+		}
+	})
+
+	b.Run("pattern", func(b *testing.B) {
+		for b.Loop() {
+			_, ok := store.Lookup("https://example50.com/@someone")
+			require.True(b, ok)
+		}
+	})
+}
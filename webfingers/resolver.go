@@ -0,0 +1,91 @@
+package webfingers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned by resolvers that have no webfinger for a resource.
+	ErrNotFound = errors.New("resource not found")
+	// ErrGone is returned by resolvers when a resource existed but was removed.
+	ErrGone = errors.New("resource is gone")
+	// ErrUnavailable is returned by resolvers that can't resolve resources
+	// right now, like when their database is down.
+	ErrUnavailable = errors.New("resolver unavailable")
+)
+
+// Resolver resolves normalized resources into their webfingers. Resolvers
+// return ErrNotFound for resources they don't know.
+type Resolver interface {
+	Resolve(ctx context.Context, resource string) (*WebFinger, error)
+}
+
+// ResolverFunc is a function that can be used as a Resolver.
+type ResolverFunc func(ctx context.Context, resource string) (*WebFinger, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ctx context.Context, resource string) (*WebFinger, error) {
+	return f(ctx, resource)
+}
+
+// Resolve returns the webfinger of the resource in the map. Patterns are only
+// matched by a Store.
+func (f WebFingers) Resolve(_ context.Context, resource string) (*WebFinger, error) {
+	if finger, ok := f[resource]; ok {
+		return finger, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, resource)
+}
+
+// Resolve returns the webfinger of the resource in the store.
+func (s *Store) Resolve(_ context.Context, resource string) (*WebFinger, error) {
+	if finger, ok := s.Lookup(resource); ok {
+		return finger, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, resource)
+}
+
+// Resolve returns the webfinger of the resource on the host in the context.
+func (s *HostStore) Resolve(ctx context.Context, resource string) (*WebFinger, error) {
+	if finger, ok := s.Lookup(HostFromContext(ctx), resource); ok {
+		return finger, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, resource)
+}
+
+// Chain returns a resolver that asks each resolver in order and returns the
+// first webfinger found, so earlier resolvers take precedence. Only
+// ErrNotFound moves on to the next resolver. Any other error, including
+// ErrGone, is returned right away.
+func Chain(resolvers ...Resolver) Resolver {
+	return ResolverFunc(func(ctx context.Context, resource string) (*WebFinger, error) {
+		for _, resolver := range resolvers {
+			finger, err := resolver.Resolve(ctx, resource)
+			if !errors.Is(err, ErrNotFound) {
+				return finger, err
+			}
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, resource)
+	})
+}
+
+type hostCtxKey struct{}
+
+// WithHost returns a copy of the context holding the host a request was made
+// to, which is used by HostStore to pick the virtual host.
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostCtxKey{}, host)
+}
+
+// HostFromContext returns the host in the context, or an empty string if there is none.
+func HostFromContext(ctx context.Context) string {
+	host, _ := ctx.Value(hostCtxKey{}).(string)
+
+	return host
+}
@@ -0,0 +1,126 @@
+package webfingers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestWebFingers_Resolve(t *testing.T) {
+	t.Parallel()
+
+	fingers := webfingers.WebFingers{
+		"acct:user@example.com": {Subject: "acct:user@example.com"},
+	}
+
+	finger, err := fingers.Resolve(t.Context(), "acct:user@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "acct:user@example.com", finger.Subject)
+
+	_, err = fingers.Resolve(t.Context(), "acct:other@example.com")
+	require.ErrorIs(t, err, webfingers.ErrNotFound)
+}
+
+func TestHostStore_Resolve(t *testing.T) {
+	t.Parallel()
+
+	store := webfingers.NewHostStore(newHosts(t), "")
+
+	// The host comes from the context
+	finger, err := store.Resolve(webfingers.WithHost(t.Context(), "example.com"), "acct:alice@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "Alice", finger.Properties["name"])
+
+	_, err = store.Resolve(t.Context(), "acct:alice@example.com")
+	require.ErrorIs(t, err, webfingers.ErrNotFound)
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	first := webfingers.WebFingers{
+		"acct:alice@example.com": {Subject: "acct:alice@example.com", Properties: map[string]string{"from": "first"}},
+	}
+	second := webfingers.WebFingers{
+		"acct:alice@example.com": {Subject: "acct:alice@example.com", Properties: map[string]string{"from": "second"}},
+		"acct:bob@example.com":   {Subject: "acct:bob@example.com", Properties: map[string]string{"from": "second"}},
+	}
+
+	failing := func(err error) webfingers.Resolver {
+		return webfingers.ResolverFunc(func(_ context.Context, resource string) (*webfingers.WebFinger, error) {
+			if resource == "acct:carol@example.com" {
+				return nil, err
+			}
+
+			return nil, webfingers.ErrNotFound
+		})
+	}
+
+	errDatabase := errors.New("database error")
+
+	tests := []struct {
+		name      string
+		resolvers []webfingers.Resolver
+		resource  string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "earlier resolvers take precedence",
+			resolvers: []webfingers.Resolver{first, second},
+			resource:  "acct:alice@example.com",
+			want:      "first",
+		},
+		{
+			name:      "moves on when not found",
+			resolvers: []webfingers.Resolver{first, second},
+			resource:  "acct:bob@example.com",
+			want:      "second",
+		},
+		{
+			name:      "returns not found when no resolver knows the resource",
+			resolvers: []webfingers.Resolver{first, second},
+			resource:  "acct:carol@example.com",
+			wantErr:   webfingers.ErrNotFound,
+		},
+		{
+			name:      "stops on errors",
+			resolvers: []webfingers.Resolver{failing(errDatabase), second},
+			resource:  "acct:carol@example.com",
+			wantErr:   errDatabase,
+		},
+		{
+			name:      "stops on gone resources",
+			resolvers: []webfingers.Resolver{failing(webfingers.ErrGone), second},
+			resource:  "acct:carol@example.com",
+			wantErr:   webfingers.ErrGone,
+		},
+		{
+			name:     "returns not found without resolvers",
+			resource: "acct:alice@example.com",
+			wantErr:  webfingers.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			finger, err := webfingers.Chain(tc.resolvers...).Resolve(t.Context(), tc.resource)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, finger.Properties["from"])
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package webfingers
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Store holds a webfinger map that can be replaced while it is being read.
+type Store struct {
+	current atomic.Pointer[snapshot]
+}
+
+// snapshot is a webfinger map and its compiled patterns.
+type snapshot struct {
+	fingers  WebFingers
+	patterns patternIndex
+}
+
+// NewStore creates a new store holding the given webfingers.
+func NewStore(fingers WebFingers) *Store {
+	s := &Store{}
+	s.Store(fingers)
+
+	return s
+}
+
+// Load returns the current webfingers.
+func (s *Store) Load() WebFingers {
+	return s.current.Load().fingers
+}
+
+// Store replaces the current webfingers.
+func (s *Store) Store(fingers WebFingers) {
+	// Never hold a nil map so lookups are always safe
+	if fingers == nil {
+		fingers = make(WebFingers)
+	}
+
+	s.current.Store(&snapshot{
+		fingers:  fingers,
+		patterns: newPatternIndex(fingers),
+	})
+}
+
+// Lookup returns the webfinger of a normalized resource. Resources in the map
+// take precedence over the ones matched by patterns, whose placeholders are
+// replaced by the values from the resource.
+func (s *Store) Lookup(resource string) (*WebFinger, bool) {
+	current := s.current.Load()
+
+	// Patterns are only looked up by matching
+	if finger, ok := current.fingers[resource]; ok && !strings.Contains(resource, UserPlaceholder) {
+		return finger, true
+	}
+
+	return current.patterns.match(resource)
+}
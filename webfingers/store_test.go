@@ -0,0 +1,60 @@
+package webfingers_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestNewStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("holds the given webfingers", func(t *testing.T) {
+		t.Parallel()
+
+		fingers := webfingers.WebFingers{
+			"acct:user@example.com": {Subject: "acct:user@example.com"},
+		}
+
+		s := webfingers.NewStore(fingers)
+
+		require.Equal(t, fingers, s.Load())
+	})
+
+	t.Run("replaces nil with an empty map", func(t *testing.T) {
+		t.Parallel()
+
+		s := webfingers.NewStore(nil)
+
+		require.NotNil(t, s.Load())
+		require.Empty(t, s.Load())
+	})
+}
+
+func TestStore_Store(t *testing.T) {
+	t.Parallel()
+
+	s := webfingers.NewStore(nil)
+
+	wg := &sync.WaitGroup{}
+
+	// Swap the webfingers while they are being read
+	for range 10 {
+		wg.Go(func() {
+			s.Store(webfingers.WebFingers{
+				"acct:user@example.com": {Subject: "acct:user@example.com"},
+			})
+		})
+
+		wg.Go(func() {
+			_ = s.Load()["acct:user@example.com"]
+		})
+	}
+
+	wg.Wait()
+
+	require.Contains(t, s.Load(), "acct:user@example.com")
+}
@@ -1,22 +1,66 @@
 package webfingers
 
 import (
+	"errors"
 	"fmt"
-	"net/mail"
+	"maps"
 	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Link is a link in a webfinger.
 type Link struct {
-	Rel  string `json:"rel"`
-	Href string `json:"href,omitempty"`
+	Rel        string            `json:"rel"                  yaml:"rel"`
+	Type       string            `json:"type,omitempty"       yaml:"type,omitempty"`
+	Href       string            `json:"href,omitempty"       yaml:"href,omitempty"`
+	Template   string            `json:"template,omitempty"   yaml:"template,omitempty"`
+	Titles     map[string]string `json:"titles,omitempty"     yaml:"titles,omitempty"`
+	Properties map[string]string `json:"properties,omitempty" yaml:"properties,omitempty"`
 }
 
 // WebFinger is a webfinger.
 type WebFinger struct {
 	Subject    string            `json:"subject"`
+	Aliases    []string          `json:"aliases,omitempty"`
 	Links      []Link            `json:"links,omitempty"`
 	Properties map[string]string `json:"properties,omitempty"`
+
+	// MaxAge is how long clients may cache the webfinger. Zero means the
+	// server default is used.
+	MaxAge time.Duration `json:"-"`
+	// ModTime is when the webfinger last changed, if known.
+	ModTime time.Time `json:"-"`
+}
+
+// sameContent reports whether two webfingers are the same apart from their ModTime.
+func (w *WebFinger) sameContent(other *WebFinger) bool {
+	a, b := *w, *other
+	a.ModTime, b.ModTime = time.Time{}, time.Time{}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// FilterLinks returns a copy of the webfinger containing only the links whose
+// relation type is one of rels. The subject, aliases and properties are kept
+// as is. If no rels are given, the webfinger is returned unchanged.
+func (w *WebFinger) FilterLinks(rels ...string) *WebFinger {
+	if len(rels) == 0 {
+		return w
+	}
+
+	filtered := *w
+	filtered.Links = nil
+
+	for _, link := range w.Links {
+		if slices.Contains(rels, link.Rel) {
+			filtered.Links = append(filtered.Links, link)
+		}
+	}
+
+	return &filtered
 }
 
 // Resources is a simplified webfinger map.
@@ -25,11 +69,145 @@ type Resources map[string]map[string]string
 // URNAliases is a map of URN aliases.
 type URNAliases map[string]string
 
-// WebFingers is a map of webfingers.
+// WebFingers is a map of webfingers, keyed by their subjects and aliases.
 type WebFingers map[string]*WebFinger
 
+// Count returns the number of webfingers in the map, not counting aliases.
+func (f WebFingers) Count() int {
+	// Keys can differ from the subject once normalized, so count the
+	// webfingers themselves
+	seen := make(map[*WebFinger]bool, len(f))
+
+	for _, finger := range f {
+		seen[finger] = true
+	}
+
+	return len(seen)
+}
+
+// SetModTimes sets the ModTime of the webfingers that don't have one. A
+// webfinger gets the ModTime of the one it replaces in previous if their
+// content is the same, and now otherwise.
+func (f WebFingers) SetModTimes(previous WebFingers, now time.Time) {
+	for resource, finger := range f {
+		if !finger.ModTime.IsZero() {
+			continue
+		}
+
+		if old, ok := previous[resource]; ok && old.sameContent(finger) {
+			finger.ModTime = old.ModTime
+		} else {
+			finger.ModTime = now
+		}
+	}
+}
+
+const (
+	// AliasesField is the resource field that holds the aliases of a resource.
+	// Multiple aliases are separated by whitespace.
+	AliasesField = "aliases"
+	// MaxAgeField is the resource field that holds how long the webfinger of
+	// a resource may be cached, as a duration like "1h".
+	MaxAgeField = "max_age"
+)
+
+var (
+	// ErrDuplicateResource is returned when two resources share a subject or alias.
+	ErrDuplicateResource = errors.New("duplicate resource")
+	// ErrInvalidMaxAge is returned when the max age of a resource is not a positive duration.
+	ErrInvalidMaxAge = errors.New("invalid max age")
+)
+
+// Descriptor describes a resource before it is turned into a webfinger.
+// Link relations and property names may be URN aliases.
+type Descriptor struct {
+	Aliases    []string          `yaml:"aliases,omitempty"`
+	Links      []Link            `yaml:"links,omitempty"`
+	Properties map[string]string `yaml:"properties,omitempty"`
+	// MaxAge is how long the webfinger may be cached, as a duration like "1h".
+	MaxAge string `yaml:"max_age,omitempty"`
+}
+
+// Descriptors is a map of resource subjects to their descriptors.
+type Descriptors map[string]*Descriptor
+
+// Descriptors converts the simplified webfinger map into descriptors. Fields
+// whose values are URIs become links and the remaining ones properties.
+func (r Resources) Descriptors() Descriptors {
+	descriptors := make(Descriptors, len(r))
+
+	for subject, fields := range r {
+		descriptor := &Descriptor{}
+
+		// Go over the fields in order so links are always in the same order
+		for _, field := range slices.Sorted(maps.Keys(fields)) {
+			value := fields[field]
+
+			switch {
+			// Aliases are not links nor properties.
+			case field == AliasesField:
+				descriptor.Aliases = append(descriptor.Aliases, strings.Fields(value)...)
+			case field == MaxAgeField:
+				descriptor.MaxAge = value
+			// If the value is a valid URI, add it to the links.
+			case isURI(value):
+				descriptor.Links = append(descriptor.Links, Link{
+					Rel:  field,
+					Href: value,
+				})
+			// Otherwise add it to the properties.
+			default:
+				if descriptor.Properties == nil {
+					descriptor.Properties = make(map[string]string)
+				}
+
+				descriptor.Properties[field] = value
+			}
+		}
+
+		descriptors[subject] = descriptor
+	}
+
+	return descriptors
+}
+
+// isURI reports whether value is an absolute URI. Placeholders are allowed.
+func isURI(value string) bool {
+	_, err := url.ParseRequestURI(withoutPlaceholders(value))
+
+	return err == nil
+}
+
+// ErrInvalidLink is returned when a link is missing required members.
+var ErrInvalidLink = errors.New("invalid link")
+
+// Validate checks that the link has the members it requires.
+func (l Link) Validate() error {
+	if l.Rel == "" {
+		return fmt.Errorf("%w: missing rel", ErrInvalidLink)
+	}
+
+	if l.Href != "" && !isURI(l.Href) {
+		return fmt.Errorf("%w: href of %s is not a URI", ErrInvalidLink, l.Rel)
+	}
+
+	return nil
+}
+
 // NewWebFingers creates a new webfinger map from a simplified webfinger map and an optional URN aliases map.
+// Resources inherit the fields of the defaults and the templates they extend, as described in Inherit.
 func NewWebFingers(resources Resources, urnAliases URNAliases) (WebFingers, error) {
+	inherited, err := Inherit(resources, func(value string) string { return value })
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWebFingersFromDescriptors(Resources(inherited).Descriptors(), urnAliases)
+}
+
+// NewWebFingersFromDescriptors creates a new webfinger map from resource
+// descriptors and an optional URN aliases map.
+func NewWebFingersFromDescriptors(descriptors Descriptors, urnAliases URNAliases) (WebFingers, error) {
 	fingers := make(WebFingers)
 
 	// If the aliases map is nil, create an empty one.
@@ -38,57 +216,122 @@ func NewWebFingers(resources Resources, urnAliases URNAliases) (WebFingers, erro
 	}
 
 	// Parse the resources.
-	for k, v := range resources {
-		subject := k
-
-		// Remove leading acct: if present.
-		if len(k) > 5 && subject[:5] == "acct:" {
-			subject = subject[5:]
+	for k, descriptor := range descriptors {
+		subject, err := DefaultNormalizer.normalizeKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing resource subject: %w", err)
 		}
 
-		// The subject must be a URL or email address.
-		if _, err := mail.ParseAddress(subject); err != nil {
-			if _, err := url.ParseRequestURI(subject); err != nil {
-				return nil, fmt.Errorf("error parsing resource subject (%s): %w", k, err)
-			}
-		} else {
-			// Add acct: back to the subject if it is an email address.
-			subject = fmt.Sprintf("acct:%s", subject)
+		finger, err := descriptor.webFinger(subject, urnAliases)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing resource %s: %w", k, err)
 		}
 
-		// Create a new webfinger.
-		finger := &WebFinger{
-			Subject: subject,
+		// Add the webfinger to the map.
+		if _, ok := fingers[subject]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateResource, subject)
 		}
 
-		// Parse the resource fields.
-		for field, value := range v {
-			fieldUrn := field
+		fingers[subject] = finger
+	}
 
-			// If the key is present in the aliases map, use its value.
-			if _, ok := urnAliases[field]; ok {
-				fieldUrn = urnAliases[field]
+	// Make sure every pattern compiles
+	for key, finger := range fingers {
+		if strings.Contains(key, UserPlaceholder) {
+			if _, err := compilePattern(key, finger); err != nil {
+				return nil, err
 			}
+		}
 
-			// If the value is a valid URI, add it to the links.
-			if _, err := url.ParseRequestURI(value); err == nil {
-				finger.Links = append(finger.Links, Link{
-					Rel:  fieldUrn,
-					Href: value,
-				})
-			} else {
-				// Otherwise add it to the properties.
-				if finger.Properties == nil {
-					finger.Properties = make(map[string]string)
+		for _, alias := range finger.Aliases {
+			if strings.Contains(alias, UserPlaceholder) {
+				if _, err := compilePattern(alias, finger); err != nil {
+					return nil, err
 				}
-
-				finger.Properties[fieldUrn] = value
 			}
 		}
+	}
 
-		// Add the webfinger to the map.
-		fingers[subject] = finger
+	// Register the aliases once every subject is known, so that an alias can
+	// never shadow a subject.
+	for _, finger := range slices.Collect(maps.Values(fingers)) {
+		for _, alias := range finger.Aliases {
+			if _, ok := fingers[alias]; ok {
+				return nil, fmt.Errorf("%w: alias %s of %s", ErrDuplicateResource, alias, finger.Subject)
+			}
+
+			fingers[alias] = finger
+		}
 	}
 
 	return fingers, nil
 }
+
+// webFinger creates the webfinger for the descriptor, replacing URN aliases
+// with their values.
+func (d *Descriptor) webFinger(subject string, urnAliases URNAliases) (*WebFinger, error) {
+	finger := &WebFinger{
+		Subject: subject,
+	}
+
+	for _, alias := range d.Aliases {
+		parsed, err := DefaultNormalizer.normalizeKey(alias)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing alias: %w", err)
+		}
+
+		// Aliases of the subject itself or repeated ones add nothing
+		if parsed == subject || slices.Contains(finger.Aliases, parsed) {
+			continue
+		}
+
+		finger.Aliases = append(finger.Aliases, parsed)
+	}
+
+	if d.MaxAge != "" {
+		maxAge, err := time.ParseDuration(d.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMaxAge, d.MaxAge)
+		}
+
+		finger.MaxAge = maxAge
+	}
+
+	for _, link := range d.Links {
+		if err := link.Validate(); err != nil {
+			return nil, err
+		}
+
+		link.Rel = urnAliases.resolve(link.Rel)
+		link.Properties = urnAliases.resolveKeys(link.Properties)
+
+		finger.Links = append(finger.Links, link)
+	}
+
+	finger.Properties = urnAliases.resolveKeys(d.Properties)
+
+	return finger, nil
+}
+
+// resolve returns the URN the name is an alias of, or the name itself.
+func (a URNAliases) resolve(name string) string {
+	if urn, ok := a[name]; ok {
+		return urn
+	}
+
+	return name
+}
+
+// resolveKeys returns a copy of the map with its keys resolved.
+func (a URNAliases) resolveKeys(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	resolved := make(map[string]string, len(m))
+	for k, v := range m {
+		resolved[a.resolve(k)] = v
+	}
+
+	return resolved
+}
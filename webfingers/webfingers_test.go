@@ -1,8 +1,10 @@
 package webfingers_test
 
 import (
+	"encoding/json"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -178,6 +180,105 @@ func TestNewWebFingers(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "parses aliases",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"aliases": "https://example.com/@user  user2@example.com",
+					"prop1":   "value1",
+				},
+			},
+			want: func() webfingers.WebFingers {
+				finger := &webfingers.WebFinger{
+					Subject: "acct:user@example.com",
+					Aliases: []string{"https://example.com/@user", "acct:user2@example.com"},
+					Properties: map[string]string{
+						"prop1": "value1",
+					},
+				}
+
+				return webfingers.WebFingers{
+					"acct:user@example.com":     finger,
+					"https://example.com/@user": finger,
+					"acct:user2@example.com":    finger,
+				}
+			}(),
+		},
+		{
+			name: "skips aliases of the subject itself",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"aliases": "acct:user@example.com https://example.com/@user https://example.com/@user",
+				},
+			},
+			want: func() webfingers.WebFingers {
+				finger := &webfingers.WebFinger{
+					Subject: "acct:user@example.com",
+					Aliases: []string{"https://example.com/@user"},
+				}
+
+				return webfingers.WebFingers{
+					"acct:user@example.com":     finger,
+					"https://example.com/@user": finger,
+				}
+			}(),
+		},
+		{
+			name: "errors on invalid alias",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"aliases": "invalid",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "errors on alias colliding with a subject",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"aliases": "acct:user2@example.com",
+				},
+				"user2@example.com": {
+					"prop1": "value1",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "errors on duplicate subjects",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"prop1": "value1",
+				},
+				"acct:user@example.com": {
+					"prop1": "value2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "parses max age",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"max_age": "1h",
+				},
+			},
+			want: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					MaxAge:  time.Hour,
+				},
+			},
+		},
+		{
+			name: "errors on invalid max age",
+			resources: webfingers.Resources{
+				"user@example.com": {
+					"max_age": "-1h",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "errors on invalid resource",
 			resources: webfingers.Resources{
@@ -215,3 +316,219 @@ func TestNewWebFingers(t *testing.T) {
 		})
 	}
 }
+
+func TestWebFinger_FilterLinks(t *testing.T) {
+	t.Parallel()
+
+	finger := &webfingers.WebFinger{
+		Subject: "acct:user@example.com",
+		Links: []webfingers.Link{
+			{Rel: "avatar", Href: "https://example.com/avatar"},
+			{Rel: "profile", Href: "https://example.com/profile"},
+			{Rel: "avatar", Href: "https://example.com/avatar2"},
+		},
+		Properties: map[string]string{
+			"name": "John Doe",
+		},
+	}
+
+	t.Run("returns the same webfinger without rels", func(t *testing.T) {
+		t.Parallel()
+
+		require.Same(t, finger, finger.FilterLinks())
+	})
+
+	t.Run("keeps only the requested rels", func(t *testing.T) {
+		t.Parallel()
+
+		got := finger.FilterLinks("avatar")
+
+		require.Equal(t, finger.Subject, got.Subject)
+		require.Equal(t, finger.Properties, got.Properties)
+		require.Equal(t, []webfingers.Link{
+			{Rel: "avatar", Href: "https://example.com/avatar"},
+			{Rel: "avatar", Href: "https://example.com/avatar2"},
+		}, got.Links)
+
+		// The original webfinger must not change
+		require.Len(t, finger.Links, 3)
+	})
+
+	t.Run("drops every link on unknown rels", func(t *testing.T) {
+		t.Parallel()
+
+		got := finger.FilterLinks("unknown")
+
+		require.Empty(t, got.Links)
+		require.Equal(t, finger.Properties, got.Properties)
+	})
+}
+
+func TestWebFingers_Count(t *testing.T) {
+	t.Parallel()
+
+	fingers, err := webfingers.NewWebFingers(
+		webfingers.Resources{
+			"user@example.com": {
+				"aliases": "https://example.com/@user https://example.com/users/user",
+			},
+			"other@example.com": {
+				"prop1": "value1",
+			},
+		},
+		nil,
+	)
+	require.NoError(t, err)
+
+	require.Len(t, fingers, 4)
+	require.Equal(t, 2, fingers.Count())
+}
+
+func TestWebFingers_SetModTimes(t *testing.T) {
+	t.Parallel()
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := before.Add(time.Hour)
+
+	previous := webfingers.WebFingers{
+		"acct:same@example.com":    {Subject: "acct:same@example.com", ModTime: before},
+		"acct:changed@example.com": {Subject: "acct:changed@example.com", ModTime: before},
+	}
+
+	fingers := webfingers.WebFingers{
+		"acct:same@example.com": {Subject: "acct:same@example.com"},
+		"acct:changed@example.com": {
+			Subject:    "acct:changed@example.com",
+			Properties: map[string]string{"name": "Changed"},
+		},
+		"acct:new@example.com": {Subject: "acct:new@example.com"},
+		"acct:set@example.com": {Subject: "acct:set@example.com", ModTime: before},
+	}
+
+	fingers.SetModTimes(previous, now)
+
+	require.Equal(t, before, fingers["acct:same@example.com"].ModTime)
+	require.Equal(t, now, fingers["acct:changed@example.com"].ModTime)
+	require.Equal(t, now, fingers["acct:new@example.com"].ModTime)
+	require.Equal(t, before, fingers["acct:set@example.com"].ModTime)
+}
+
+func TestNewWebFingersFromDescriptors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		descriptors webfingers.Descriptors
+		urnAliases  webfingers.URNAliases
+		want        webfingers.WebFingers
+		wantErr     bool
+	}{
+		{
+			name: "keeps every link member",
+			descriptors: webfingers.Descriptors{
+				"user@example.com": {
+					Links: []webfingers.Link{
+						{
+							Rel:  "self",
+							Type: "application/activity+json",
+							Href: "https://example.com/users/user",
+							Titles: map[string]string{
+								"en-us": "User",
+								"und":   "User",
+							},
+							Properties: map[string]string{
+								"name": "value",
+							},
+						},
+						{
+							Rel:      "subscribe",
+							Template: "https://example.com/authorize?uri={uri}",
+						},
+					},
+				},
+			},
+			urnAliases: webfingers.URNAliases{
+				"subscribe": "http://ostatus.org/schema/1.0/subscribe",
+				"name":      "http://schema.org/name",
+			},
+			want: webfingers.WebFingers{
+				"acct:user@example.com": {
+					Subject: "acct:user@example.com",
+					Links: []webfingers.Link{
+						{
+							Rel:  "self",
+							Type: "application/activity+json",
+							Href: "https://example.com/users/user",
+							Titles: map[string]string{
+								"en-us": "User",
+								"und":   "User",
+							},
+							Properties: map[string]string{
+								"http://schema.org/name": "value",
+							},
+						},
+						{
+							Rel:      "http://ostatus.org/schema/1.0/subscribe",
+							Template: "https://example.com/authorize?uri={uri}",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "errors on links without rel",
+			descriptors: webfingers.Descriptors{
+				"user@example.com": {
+					Links: []webfingers.Link{
+						{Href: "https://example.com/users/user"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "errors on links with invalid href",
+			descriptors: webfingers.Descriptors{
+				"user@example.com": {
+					Links: []webfingers.Link{
+						{Rel: "self", Href: "invalid"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := webfingers.NewWebFingersFromDescriptors(tc.descriptors, tc.urnAliases)
+			require.Equal(t, tc.wantErr, err != nil)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLink_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	link := webfingers.Link{
+		Rel:      "http://ostatus.org/schema/1.0/subscribe",
+		Template: "https://example.com/authorize?uri={uri}",
+		Titles: map[string]string{
+			"und": "Subscribe",
+		},
+	}
+
+	got, err := json.Marshal(link)
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{
+		"rel": "http://ostatus.org/schema/1.0/subscribe",
+		"template": "https://example.com/authorize?uri={uri}",
+		"titles": {"und": "Subscribe"}
+	}`, string(got))
+}
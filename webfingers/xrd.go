@@ -0,0 +1,91 @@
+package webfingers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// XRDNamespace is the XML namespace of XRD 1.0 documents.
+const XRDNamespace = "http://docs.oasis-open.org/ns/xri/xrd-1.0"
+
+// undeterminedLanguage is the language tag JRD uses for titles without a language.
+const undeterminedLanguage = "und"
+
+type xrdDocument struct {
+	XMLName    xml.Name      `xml:"http://docs.oasis-open.org/ns/xri/xrd-1.0 XRD"`
+	Subject    string        `xml:"Subject,omitempty"`
+	Aliases    []string      `xml:"Alias"`
+	Properties []xrdProperty `xml:"Property"`
+	Links      []xrdLink     `xml:"Link"`
+}
+
+type xrdProperty struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xrdTitle struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type xrdLink struct {
+	Rel        string        `xml:"rel,attr"`
+	Type       string        `xml:"type,attr,omitempty"`
+	Href       string        `xml:"href,attr,omitempty"`
+	Template   string        `xml:"template,attr,omitempty"`
+	Titles     []xrdTitle    `xml:"Title"`
+	Properties []xrdProperty `xml:"Property"`
+}
+
+// xrdProperties converts a properties map into XRD properties sorted by type.
+func xrdProperties(properties map[string]string) []xrdProperty {
+	xrdProps := make([]xrdProperty, 0, len(properties))
+
+	for _, k := range slices.Sorted(maps.Keys(properties)) {
+		xrdProps = append(xrdProps, xrdProperty{Type: k, Value: properties[k]})
+	}
+
+	return xrdProps
+}
+
+// MarshalXRD encodes the webfinger as an XRD 1.0 document, including the XML header.
+func (w *WebFinger) MarshalXRD() ([]byte, error) {
+	doc := xrdDocument{
+		Subject:    w.Subject,
+		Aliases:    w.Aliases,
+		Properties: xrdProperties(w.Properties),
+	}
+
+	for _, link := range w.Links {
+		xrdL := xrdLink{
+			Rel:        link.Rel,
+			Type:       link.Type,
+			Href:       link.Href,
+			Template:   link.Template,
+			Properties: xrdProperties(link.Properties),
+		}
+
+		for _, lang := range slices.Sorted(maps.Keys(link.Titles)) {
+			title := xrdTitle{Lang: lang, Value: link.Titles[lang]}
+
+			// Titles with no language have no xml:lang in XRD
+			if lang == undeterminedLanguage {
+				title.Lang = ""
+			}
+
+			xrdL.Titles = append(xrdL.Titles, title)
+		}
+
+		doc.Links = append(doc.Links, xrdL)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding XRD: %w", err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,71 @@
+package webfingers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"git.maronato.dev/maronato/finger/webfingers"
+)
+
+func TestWebFinger_MarshalXRD(t *testing.T) {
+	t.Parallel()
+
+	t.Run("encodes every member", func(t *testing.T) {
+		t.Parallel()
+
+		finger := &webfingers.WebFinger{
+			Subject: "acct:user@example.com",
+			Aliases: []string{"https://example.com/@user"},
+			Links: []webfingers.Link{
+				{
+					Rel:  "self",
+					Type: "application/activity+json",
+					Href: "https://example.com/users/user",
+					Titles: map[string]string{
+						"und":   "User",
+						"en-us": "User & co",
+					},
+					Properties: map[string]string{
+						"http://example.com/ns/prop": "value",
+					},
+				},
+				{
+					Rel:      "http://ostatus.org/schema/1.0/subscribe",
+					Template: "https://example.com/authorize?uri={uri}",
+				},
+			},
+			Properties: map[string]string{
+				"http://schema.org/name": "John Doe",
+			},
+		}
+
+		got, err := finger.MarshalXRD()
+		require.NoError(t, err)
+
+		want := `<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+  <Subject>acct:user@example.com</Subject>
+  <Alias>https://example.com/@user</Alias>
+  <Property type="http://schema.org/name">John Doe</Property>
+  <Link rel="self" type="application/activity+json" href="https://example.com/users/user">
+    <Title xml:lang="en-us">User &amp; co</Title>
+    <Title>User</Title>
+    <Property type="http://example.com/ns/prop">value</Property>
+  </Link>
+  <Link rel="http://ostatus.org/schema/1.0/subscribe" template="https://example.com/authorize?uri={uri}"></Link>
+</XRD>`
+
+		require.Equal(t, want, string(got))
+	})
+
+	t.Run("omits an empty subject", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := (&webfingers.WebFinger{}).MarshalXRD()
+		require.NoError(t, err)
+
+		require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0"></XRD>`, string(got))
+	})
+}